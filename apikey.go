@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/db"
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+const apiKeyUsage = `usage: gds-OneCV [flags] apikey <command>
+
+commands:
+  create -teacher EMAIL [-name NAME]   issue a key that acts as the teacher
+  create -admin [-name NAME]           issue an administrator key
+  create -role ROLE [-name NAME]       issue a key with another role, such as auditor
+  revoke ID                            revoke a key`
+
+func runAPIKey(cfg config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	conn, err := db.OpenConnection(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	s := store.NewPostgres(conn)
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ExitOnError)
+		teacher := fs.String("teacher", "", "email of the teacher the key acts as")
+		admin := fs.Bool("admin", false, "issue an administrator key; short for -role admin")
+		role := fs.String("role", auth.RoleTeacher, "role granting the key its permissions")
+		name := fs.String("name", "", "label to recognise the key by")
+		fs.Parse(args[1:])
+
+		key := store.APIKey{Name: *name, Role: *role, Teacher: *teacher}
+		if *admin {
+			key.Role = auth.RoleAdmin
+		}
+		if key.Role == auth.RoleTeacher && key.Teacher == "" {
+			log.Fatal("-teacher is required for teacher keys")
+		}
+		if key.Teacher != "" {
+			email, ok := models.NormalizeEmail(key.Teacher)
+			if !ok {
+				log.Fatalf("-teacher %q is not a valid email", key.Teacher)
+			}
+			key.Teacher = email
+		}
+
+		token, hash, err := auth.NewToken()
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, err = s.CreateAPIKey(ctx, key, hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("created %s key %d; it will not be shown again:\n%s\n", key.Role, key.ID, token)
+	case "revoke":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, apiKeyUsage)
+			os.Exit(2)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid key ID: %s", args[1])
+		}
+		if err := s.RevokeAPIKey(ctx, id); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("revoked key %d\n", id)
+	default:
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,508 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings for the server and its database connection.
+// Load fills it from, in increasing order of precedence, the defaults, an
+// optional YAML or JSON file, the environment and command-line flags.
+type Config struct {
+	Server    ServerConfig    `json:"server" yaml:"server"`
+	Database  DatabaseConfig  `json:"database" yaml:"database"`
+	RateLimit RateLimitConfig `json:"rateLimit" yaml:"rateLimit"`
+	Delivery  DeliveryConfig  `json:"delivery" yaml:"delivery"`
+	Webhooks  WebhookConfig   `json:"webhooks" yaml:"webhooks"`
+}
+
+type ServerConfig struct {
+	ListenAddr   string   `json:"listenAddr" yaml:"listenAddr"`
+	ReadTimeout  Duration `json:"readTimeout" yaml:"readTimeout"`
+	WriteTimeout Duration `json:"writeTimeout" yaml:"writeTimeout"`
+	IdleTimeout  Duration `json:"idleTimeout" yaml:"idleTimeout"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// once the server is asked to stop.
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+	// ReadinessTimeout bounds the database checks made by /readyz.
+	ReadinessTimeout Duration `json:"readinessTimeout" yaml:"readinessTimeout"`
+	SweepInterval    Duration `json:"sweepInterval" yaml:"sweepInterval"`
+}
+
+// DatabaseConfig describes the Postgres connection either as a full DSN or
+// as its individual parts. A DSN takes precedence over the parts.
+type DatabaseConfig struct {
+	DSN            string   `json:"dsn" yaml:"dsn"`
+	Host           string   `json:"host" yaml:"host"`
+	Port           int      `json:"port" yaml:"port"`
+	User           string   `json:"user" yaml:"user"`
+	Password       string   `json:"password" yaml:"password"`
+	Name           string   `json:"name" yaml:"name"`
+	SSLMode        string   `json:"sslMode" yaml:"sslMode"`
+	ConnectTimeout Duration `json:"connectTimeout" yaml:"connectTimeout"`
+	// StartupTimeout is how long the server keeps retrying an unreachable
+	// database at startup before giving up.
+	StartupTimeout  Duration `json:"startupTimeout" yaml:"startupTimeout"`
+	MaxOpenConns    int      `json:"maxOpenConns" yaml:"maxOpenConns"`
+	MaxIdleConns    int      `json:"maxIdleConns" yaml:"maxIdleConns"`
+	ConnMaxLifetime Duration `json:"connMaxLifetime" yaml:"connMaxLifetime"`
+}
+
+// RateLimitConfig sets how many requests each API key, or each client IP
+// for unauthenticated requests, may make per route. Routes are keyed by
+// method and path template, such as "POST /api/retrievefornotifications",
+// and fall back to Default. PerIP caps all requests from one IP address
+// across routes, and is checked before the API key.
+type RateLimitConfig struct {
+	Enabled bool                 `json:"enabled" yaml:"enabled"`
+	Backend string               `json:"backend" yaml:"backend"`
+	PerIP   RateLimit            `json:"perIP" yaml:"perIP"`
+	Default RateLimit            `json:"default" yaml:"default"`
+	Routes  map[string]RateLimit `json:"routes" yaml:"routes"`
+}
+
+// RateLimit is a token bucket refilled at RequestsPerMinute that holds up to
+// Burst requests.
+type RateLimit struct {
+	RequestsPerMinute float64 `json:"requestsPerMinute" yaml:"requestsPerMinute"`
+	Burst             int     `json:"burst" yaml:"burst"`
+}
+
+// DeliveryConfig controls the workers that send notifications from the
+// outbox. A delivery that fails is retried after RetryBackoff, doubling for
+// every further attempt up to MaxRetryBackoff, until MaxAttempts have been
+// made.
+type DeliveryConfig struct {
+	Enabled         bool       `json:"enabled" yaml:"enabled"`
+	Workers         int        `json:"workers" yaml:"workers"`
+	PollInterval    Duration   `json:"pollInterval" yaml:"pollInterval"`
+	SendTimeout     Duration   `json:"sendTimeout" yaml:"sendTimeout"`
+	MaxAttempts     int        `json:"maxAttempts" yaml:"maxAttempts"`
+	RetryBackoff    Duration   `json:"retryBackoff" yaml:"retryBackoff"`
+	MaxRetryBackoff Duration   `json:"maxRetryBackoff" yaml:"maxRetryBackoff"`
+	SMTP            SMTPConfig `json:"smtp" yaml:"smtp"`
+}
+
+// WebhookConfig controls the workers that send events to webhooks, which
+// retry failed requests like DeliveryConfig. SendTimeout bounds each request.
+type WebhookConfig struct {
+	Workers         int      `json:"workers" yaml:"workers"`
+	PollInterval    Duration `json:"pollInterval" yaml:"pollInterval"`
+	SendTimeout     Duration `json:"sendTimeout" yaml:"sendTimeout"`
+	MaxAttempts     int      `json:"maxAttempts" yaml:"maxAttempts"`
+	RetryBackoff    Duration `json:"retryBackoff" yaml:"retryBackoff"`
+	MaxRetryBackoff Duration `json:"maxRetryBackoff" yaml:"maxRetryBackoff"`
+}
+
+// SMTPConfig describes the mail server used to email notifications to
+// students. The connection is upgraded with STARTTLS whenever the server
+// offers it.
+type SMTPConfig struct {
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	From     string `json:"from" yaml:"from"`
+}
+
+const (
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+)
+
+// For returns the limit for the route with the given method and path
+// template.
+func (c RateLimitConfig) For(method, route string) RateLimit {
+	if limit, ok := c.Routes[method+" "+route]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			ListenAddr:       ":8000",
+			ReadTimeout:      Duration(10 * time.Second),
+			WriteTimeout:     Duration(30 * time.Second),
+			IdleTimeout:      Duration(2 * time.Minute),
+			ShutdownTimeout:  Duration(30 * time.Second),
+			ReadinessTimeout: Duration(2 * time.Second),
+			SweepInterval:    Duration(time.Minute),
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			Name:            "school",
+			SSLMode:         "disable",
+			ConnectTimeout:  Duration(5 * time.Second),
+			StartupTimeout:  Duration(time.Minute),
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Backend: RateLimitBackendMemory,
+			PerIP:   RateLimit{RequestsPerMinute: 1200, Burst: 120},
+			Default: RateLimit{RequestsPerMinute: 600, Burst: 60},
+		},
+		Delivery: DeliveryConfig{
+			Workers:         4,
+			PollInterval:    Duration(5 * time.Second),
+			SendTimeout:     Duration(30 * time.Second),
+			MaxAttempts:     5,
+			RetryBackoff:    Duration(30 * time.Second),
+			MaxRetryBackoff: Duration(time.Hour),
+			SMTP:            SMTPConfig{Port: 587},
+		},
+		Webhooks: WebhookConfig{
+			Workers:         2,
+			PollInterval:    Duration(5 * time.Second),
+			SendTimeout:     Duration(10 * time.Second),
+			MaxAttempts:     8,
+			RetryBackoff:    Duration(30 * time.Second),
+			MaxRetryBackoff: Duration(time.Hour),
+		},
+	}
+}
+
+// Load builds and validates the configuration. It returns the arguments left
+// over after the flags, such as a subcommand.
+func Load(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("gds-OneCV", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML or JSON configuration file")
+	listenAddr := fs.String("listen", "", "address for the HTTP server to listen on")
+	dsn := fs.String("db-dsn", "", "full Postgres connection string")
+	host := fs.String("db-host", "", "Postgres host")
+	port := fs.Int("db-port", 0, "Postgres port")
+	user := fs.String("db-user", "", "Postgres user")
+	name := fs.String("db-name", "", "Postgres database name")
+	sslMode := fs.String("db-sslmode", "", "Postgres sslmode")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg, getenv); err != nil {
+		return Config{}, nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Server.ListenAddr = *listenAddr
+		case "db-dsn":
+			cfg.Database.DSN = *dsn
+		case "db-host":
+			cfg.Database.Host = *host
+		case "db-port":
+			cfg.Database.Port = *port
+		case "db-user":
+			cfg.Database.User = *user
+		case "db-name":
+			cfg.Database.Name = *name
+		case "db-sslmode":
+			cfg.Database.SSLMode = *sslMode
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s must end in .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	stringVars := map[string]*string{
+		"LISTEN_ADDR":  &cfg.Server.ListenAddr,
+		"DATABASE_URL": &cfg.Database.DSN,
+		"DB_HOST":      &cfg.Database.Host,
+		"DB_USER":      &cfg.Database.User,
+		"DB_PASSWORD":  &cfg.Database.Password,
+		"DB_NAME":      &cfg.Database.Name,
+		"DB_SSLMODE":   &cfg.Database.SSLMode,
+
+		"RATE_LIMIT_BACKEND": &cfg.RateLimit.Backend,
+
+		"SMTP_HOST":     &cfg.Delivery.SMTP.Host,
+		"SMTP_USERNAME": &cfg.Delivery.SMTP.Username,
+		"SMTP_PASSWORD": &cfg.Delivery.SMTP.Password,
+		"SMTP_FROM":     &cfg.Delivery.SMTP.From,
+	}
+	for key, field := range stringVars {
+		if value := getenv(key); value != "" {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"DB_PORT":           &cfg.Database.Port,
+		"DB_MAX_OPEN_CONNS": &cfg.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &cfg.Database.MaxIdleConns,
+		"RATE_LIMIT_BURST":  &cfg.RateLimit.Default.Burst,
+		"DELIVERY_WORKERS":  &cfg.Delivery.Workers,
+		"WEBHOOK_WORKERS":   &cfg.Webhooks.Workers,
+		"SMTP_PORT":         &cfg.Delivery.SMTP.Port,
+	}
+	for key, field := range intVars {
+		if value := getenv(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a number: %q", key, value)
+			}
+			*field = n
+		}
+	}
+
+	durationVars := map[string]*Duration{
+		"DB_CONNECT_TIMEOUT":        &cfg.Database.ConnectTimeout,
+		"DB_STARTUP_TIMEOUT":        &cfg.Database.StartupTimeout,
+		"DB_CONN_MAX_LIFETIME":      &cfg.Database.ConnMaxLifetime,
+		"SERVER_READ_TIMEOUT":       &cfg.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":      &cfg.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":       &cfg.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":   &cfg.Server.ShutdownTimeout,
+		"SERVER_READINESS_TIMEOUT":  &cfg.Server.ReadinessTimeout,
+		"SUSPENSION_SWEEP_INTERVAL": &cfg.Server.SweepInterval,
+	}
+	for key, field := range durationVars {
+		if value := getenv(key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration such as 30s: %q", key, value)
+			}
+			*field = Duration(d)
+		}
+	}
+
+	boolVars := map[string]*bool{
+		"RATE_LIMIT_ENABLED": &cfg.RateLimit.Enabled,
+		"DELIVERY_ENABLED":   &cfg.Delivery.Enabled,
+	}
+	for key, field := range boolVars {
+		if value := getenv(key); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false: %q", key, value)
+			}
+			*field = enabled
+		}
+	}
+	if value := getenv("RATE_LIMIT_RPM"); value != "" {
+		rpm, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_RPM must be a number: %q", value)
+		}
+		cfg.RateLimit.Default.RequestsPerMinute = rpm
+	}
+	return nil
+}
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Server.ListenAddr == "" {
+		problems = append(problems, "server listen address is required")
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.ShutdownTimeout < 0 {
+		problems = append(problems, "server timeouts must not be negative")
+	}
+	if c.Server.ReadinessTimeout <= 0 {
+		problems = append(problems, "readiness timeout must be positive")
+	}
+	if c.Server.SweepInterval <= 0 {
+		problems = append(problems, "suspension sweep interval must be positive")
+	}
+
+	db := c.Database
+	if db.DSN == "" {
+		if db.Host == "" {
+			problems = append(problems, "database host is required when no DSN is given")
+		}
+		if db.Port < 1 || db.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("database port %d is out of range", db.Port))
+		}
+		if db.Name == "" {
+			problems = append(problems, "database name is required when no DSN is given")
+		}
+		if !sslModes[db.SSLMode] {
+			problems = append(problems, fmt.Sprintf("database sslmode %q is not one of disable, require, verify-ca, verify-full", db.SSLMode))
+		}
+	}
+	if db.MaxOpenConns < 0 || db.MaxIdleConns < 0 {
+		problems = append(problems, "database pool sizes must not be negative")
+	}
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		problems = append(problems, "database max idle connections must not exceed max open connections")
+	}
+	if db.ConnectTimeout < 0 || db.ConnMaxLifetime < 0 || db.StartupTimeout < 0 {
+		problems = append(problems, "database timeouts must not be negative")
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Backend != RateLimitBackendMemory && c.RateLimit.Backend != RateLimitBackendPostgres {
+			problems = append(problems, fmt.Sprintf("rate limit backend %q is not one of memory, postgres", c.RateLimit.Backend))
+		}
+		routes := []string{"perIP", "default"}
+		limits := map[string]RateLimit{"perIP": c.RateLimit.PerIP, "default": c.RateLimit.Default}
+		for route, limit := range c.RateLimit.Routes {
+			routes = append(routes, route)
+			limits[route] = limit
+		}
+		sort.Strings(routes[2:])
+		for _, route := range routes {
+			if limit := limits[route]; limit.RequestsPerMinute <= 0 || limit.Burst < 1 {
+				problems = append(problems, fmt.Sprintf("rate limit for %s needs a positive requestsPerMinute and a burst of at least 1", route))
+			}
+		}
+	}
+
+	if d := c.Delivery; d.Enabled {
+		if d.Workers < 1 {
+			problems = append(problems, "delivery needs at least 1 worker")
+		}
+		if d.PollInterval <= 0 || d.SendTimeout <= 0 || d.RetryBackoff <= 0 || d.MaxRetryBackoff < d.RetryBackoff {
+			problems = append(problems, "delivery intervals must be positive, with maxRetryBackoff at least retryBackoff")
+		}
+		if d.MaxAttempts < 1 {
+			problems = append(problems, "delivery needs at least 1 attempt")
+		}
+		if d.SMTP.Host == "" {
+			problems = append(problems, "smtp host is required when delivery is enabled")
+		}
+		if d.SMTP.Port < 1 || d.SMTP.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("smtp port %d is out of range", d.SMTP.Port))
+		}
+		if _, err := mail.ParseAddress(d.SMTP.From); err != nil {
+			problems = append(problems, fmt.Sprintf("smtp from address %q is not a valid email", d.SMTP.From))
+		}
+	}
+
+	if w := c.Webhooks; w.Workers < 1 || w.MaxAttempts < 1 {
+		problems = append(problems, "webhooks need at least 1 worker and 1 attempt")
+	}
+	if w := c.Webhooks; w.PollInterval <= 0 || w.SendTimeout <= 0 || w.RetryBackoff <= 0 || w.MaxRetryBackoff < w.RetryBackoff {
+		problems = append(problems, "webhook intervals must be positive, with maxRetryBackoff at least retryBackoff")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ConnectionString returns the DSN if one was given and otherwise builds a
+// key/value connection string from the individual settings.
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	parts := []string{
+		"host=" + quote(d.Host),
+		"port=" + strconv.Itoa(d.Port),
+		"dbname=" + quote(d.Name),
+		"sslmode=" + quote(d.SSLMode),
+	}
+	if d.User != "" {
+		parts = append(parts, "user="+quote(d.User))
+	}
+	if d.Password != "" {
+		parts = append(parts, "password="+quote(d.Password))
+	}
+	if seconds := int(time.Duration(d.ConnectTimeout).Seconds()); seconds > 0 {
+		parts = append(parts, "connect_timeout="+strconv.Itoa(seconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config
+// files.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	return d.set(value)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.set(node.Value)
+}
+
+func (d *Duration) set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
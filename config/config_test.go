@@ -0,0 +1,189 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load([]string{"migrate", "up"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.ListenAddr != ":8000" {
+		t.Errorf("Expected default listen address :8000; got %s", cfg.Server.ListenAddr)
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("Expected remaining args 'migrate up'; got %v", args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `
+server:
+  listenAddr: ":9000"
+  readTimeout: 3s
+database:
+  host: db.internal
+  name: fromfile
+  maxOpenConns: 40
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load(
+		[]string{"-config", path, "-db-name", "fromflag"},
+		env(map[string]string{"DB_NAME": "fromenv", "DB_HOST": "env.internal", "DB_USER": "teacher"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.ListenAddr != ":9000" || time.Duration(cfg.Server.ReadTimeout) != 3*time.Second {
+		t.Errorf("Expected server settings from the file; got %+v", cfg.Server)
+	}
+	if cfg.Database.MaxOpenConns != 40 {
+		t.Errorf("Expected maxOpenConns 40 from the file; got %d", cfg.Database.MaxOpenConns)
+	}
+	if cfg.Database.Host != "env.internal" {
+		t.Errorf("Expected the environment to override the file; got host %s", cfg.Database.Host)
+	}
+	if cfg.Database.Name != "fromflag" {
+		t.Errorf("Expected the flag to override the environment; got name %s", cfg.Database.Name)
+	}
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	contents := `{"database": {"dsn": "postgres://localhost/school", "connMaxLifetime": "1h"}}`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load([]string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.ConnectionString() != "postgres://localhost/school" {
+		t.Errorf("Expected the DSN to be used as is; got %s", cfg.Database.ConnectionString())
+	}
+	if time.Duration(cfg.Database.ConnMaxLifetime) != time.Hour {
+		t.Errorf("Expected connMaxLifetime 1h; got %s", cfg.Database.ConnMaxLifetime)
+	}
+}
+
+func TestLoadRejectsUnknownFileFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"server": {"port": 8080}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Load([]string{"-config", path}, env(nil)); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Port = 0
+	cfg.Database.SSLMode = "prefer"
+	cfg.Database.MaxOpenConns = 2
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	for _, problem := range []string{"port 0", "sslmode \"prefer\"", "max idle connections"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q in %q", problem, err.Error())
+		}
+	}
+}
+
+func TestConnectionString(t *testing.T) {
+	cfg := Default().Database
+	cfg.User = "teacher"
+	cfg.Password = `it's secret`
+
+	expected := `host='localhost' port=5432 dbname='school' sslmode='disable' user='teacher' password='it\'s secret' connect_timeout=5`
+	if got := cfg.ConnectionString(); got != expected {
+		t.Errorf("Expected %s; got %s", expected, got)
+	}
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `
+rateLimit:
+  default:
+    requestsPerMinute: 120
+    burst: 20
+  routes:
+    POST /api/retrievefornotifications:
+      requestsPerMinute: 6
+      burst: 2
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load([]string{"-config", path}, env(map[string]string{"RATE_LIMIT_BACKEND": "postgres"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.RateLimit.Enabled || cfg.RateLimit.Backend != RateLimitBackendPostgres {
+		t.Errorf("Expected rate limiting enabled with the postgres backend; got %+v", cfg.RateLimit)
+	}
+	if limit := cfg.RateLimit.For("POST", "/api/retrievefornotifications"); limit != (RateLimit{RequestsPerMinute: 6, Burst: 2}) {
+		t.Errorf("Expected the route's own limit; got %+v", limit)
+	}
+	if limit := cfg.RateLimit.For("GET", "/api/students"); limit != (RateLimit{RequestsPerMinute: 120, Burst: 20}) {
+		t.Errorf("Expected the default limit; got %+v", limit)
+	}
+
+	_, _, err = Load([]string{"-config", path}, env(map[string]string{"RATE_LIMIT_BURST": "0"}))
+	if err == nil || !strings.Contains(err.Error(), "rate limit for default") {
+		t.Errorf("Expected a rate limit validation error; got %v", err)
+	}
+}
+
+func TestDeliveryConfig(t *testing.T) {
+	cfg, _, err := Load(nil, env(map[string]string{
+		"DELIVERY_ENABLED": "true",
+		"SMTP_HOST":        "smtp.internal",
+		"SMTP_PORT":        "2525",
+		"SMTP_FROM":        "notifications@school.edu",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Delivery.Enabled || cfg.Delivery.SMTP.Host != "smtp.internal" || cfg.Delivery.SMTP.Port != 2525 {
+		t.Errorf("Expected delivery over smtp.internal:2525; got %+v", cfg.Delivery)
+	}
+
+	cfg = Default()
+	cfg.Delivery.Enabled = true
+	cfg.Delivery.Workers = 0
+	cfg.Delivery.SMTP.From = "Notifications"
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	for _, problem := range []string{"at least 1 worker", "smtp host is required", `from address "Notifications"`} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q in %q", problem, err.Error())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// EmailCollision is a set of teacher or student emails that differ only in
+// case and so name the same person once emails are compared
+// case-insensitively.
+type EmailCollision struct {
+	Table  string
+	Email  string
+	Emails []string
+}
+
+// EmailCollisions lists the teachers and students that stop the
+// lowercase_emails migration from being applied.
+func EmailCollisions(ctx context.Context, db *sql.DB) ([]EmailCollision, error) {
+	query := `
+		SELECT 'teachers', lower(teacher_email), array_agg(teacher_email ORDER BY teacher_email)
+		FROM teachers
+		GROUP BY lower(teacher_email)
+		HAVING COUNT(*) > 1
+		UNION ALL
+		SELECT 'students', lower(student_email), array_agg(student_email ORDER BY student_email)
+		FROM students
+		GROUP BY lower(student_email)
+		HAVING COUNT(*) > 1
+		ORDER BY 1, 2
+	`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collisions []EmailCollision
+	for rows.Next() {
+		var collision EmailCollision
+		if err := rows.Scan(&collision.Table, &collision.Email, pq.Array(&collision.Emails)); err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, collision)
+	}
+	return collisions, rows.Err()
+}
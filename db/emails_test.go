@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leeshuoan/gds-OneCV/mocks"
+)
+
+func TestEmailCollisions(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery(`GROUP BY lower\(teacher_email\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"table", "email", "emails"}).
+			AddRow("students", "studentjon@gmail.com", `{StudentJon@gmail.com,studentjon@gmail.com}`))
+
+	collisions, err := EmailCollisions(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EmailCollision{{
+		Table:  "students",
+		Email:  "studentjon@gmail.com",
+		Emails: []string{"StudentJon@gmail.com", "studentjon@gmail.com"},
+	}}
+	if !reflect.DeepEqual(collisions, expected) {
+		t.Errorf("Expected %v; got %v", expected, collisions)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
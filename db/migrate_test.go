@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leeshuoan/gds-OneCV/mocks"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d; got %d", i, i+1, migration.Version)
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsMissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_initial.up.sql": {Data: []byte("CREATE TABLE a ();")},
+	}
+
+	if _, err := loadMigrations(fsys); err == nil {
+		t.Error("Expected an error for a migration without a down file")
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	for _, migration := range migrations[1:] {
+		mock.ExpectExec(`.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations`).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	applied, err := MigrateUp(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations)-1 {
+		t.Errorf("Expected %d migrations to be applied; got %d", len(migrations)-1, len(applied))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectExec(`DROP TABLE IF EXISTS registrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := MigrateDown(context.Background(), db, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 1 {
+		t.Errorf("Expected only migration 1 to be reverted; got %v", reverted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
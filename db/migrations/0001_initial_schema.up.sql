@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS teachers (
+    teacher_email text PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS students (
+    student_email text PRIMARY KEY,
+    is_suspended boolean DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS registrations (
+    registration_id serial PRIMARY KEY,
+    teacher_email text REFERENCES teachers(teacher_email),
+    student_email text REFERENCES students(student_email),
+    UNIQUE (teacher_email, student_email)
+);
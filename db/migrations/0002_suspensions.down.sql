@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS suspensions;
+
+ALTER TABLE students DROP COLUMN IF EXISTS suspended_until;
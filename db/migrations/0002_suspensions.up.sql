@@ -0,0 +1,14 @@
+ALTER TABLE students ADD COLUMN IF NOT EXISTS suspended_until timestamptz;
+
+CREATE TABLE IF NOT EXISTS suspensions (
+    suspension_id serial PRIMARY KEY,
+    student_email text NOT NULL REFERENCES students(student_email),
+    suspended_by text NOT NULL DEFAULT '',
+    reason text NOT NULL DEFAULT '',
+    started_at timestamptz NOT NULL DEFAULT now(),
+    ends_at timestamptz,
+    ended_at timestamptz,
+    end_reason text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS suspensions_active_idx ON suspensions (student_email) WHERE ended_at IS NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id serial PRIMARY KEY,
+    token_hash text NOT NULL UNIQUE,
+    name text NOT NULL DEFAULT '',
+    role text NOT NULL CHECK (role IN ('teacher', 'admin')),
+    teacher_email text REFERENCES teachers(teacher_email) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz,
+    CHECK (role <> 'teacher' OR teacher_email IS NOT NULL)
+);
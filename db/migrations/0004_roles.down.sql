@@ -0,0 +1,7 @@
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_role_fkey;
+DELETE FROM api_keys WHERE role NOT IN ('teacher', 'admin');
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_role_check CHECK (role IN ('teacher', 'admin'));
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS roles (
+    role text PRIMARY KEY,
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    permission text PRIMARY KEY,
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role text NOT NULL REFERENCES roles(role) ON DELETE CASCADE,
+    permission text NOT NULL REFERENCES permissions(permission) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (role, description) VALUES
+    ('admin', 'Manages teachers and students and suspends students'),
+    ('teacher', 'Manages their own roster and sends notifications'),
+    ('auditor', 'Reads everything and changes nothing')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (permission, description) VALUES
+    ('teachers:read', 'List and view teachers'),
+    ('teachers:write', 'Create, rename and delete teachers'),
+    ('students:read', 'List and view students'),
+    ('students:write', 'Create, rename and delete students'),
+    ('registrations:read', 'Query the students common to teachers'),
+    ('registrations:write', 'Register and deregister students'),
+    ('suspensions:read', 'View suspension history'),
+    ('suspensions:write', 'Suspend and unsuspend students'),
+    ('notifications:send', 'Resolve notification recipients')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', permission FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('teacher', 'teachers:read'),
+    ('teacher', 'students:read'),
+    ('teacher', 'registrations:read'),
+    ('teacher', 'registrations:write'),
+    ('teacher', 'suspensions:read'),
+    ('teacher', 'notifications:send'),
+    ('auditor', 'teachers:read'),
+    ('auditor', 'students:read'),
+    ('auditor', 'registrations:read'),
+    ('auditor', 'suspensions:read')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_role_check;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_role_fkey FOREIGN KEY (role) REFERENCES roles(role);
@@ -0,0 +1,16 @@
+-- The original case of lowercased emails is not restored.
+DROP INDEX IF EXISTS students_email_lower_idx;
+DROP INDEX IF EXISTS teachers_email_lower_idx;
+
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_teacher_email_fkey;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_teacher_email_fkey
+    FOREIGN KEY (teacher_email) REFERENCES teachers(teacher_email) ON DELETE CASCADE;
+ALTER TABLE suspensions DROP CONSTRAINT suspensions_student_email_fkey;
+ALTER TABLE suspensions ADD CONSTRAINT suspensions_student_email_fkey
+    FOREIGN KEY (student_email) REFERENCES students(student_email);
+ALTER TABLE registrations DROP CONSTRAINT registrations_student_email_fkey;
+ALTER TABLE registrations ADD CONSTRAINT registrations_student_email_fkey
+    FOREIGN KEY (student_email) REFERENCES students(student_email);
+ALTER TABLE registrations DROP CONSTRAINT registrations_teacher_email_fkey;
+ALTER TABLE registrations ADD CONSTRAINT registrations_teacher_email_fkey
+    FOREIGN KEY (teacher_email) REFERENCES teachers(teacher_email);
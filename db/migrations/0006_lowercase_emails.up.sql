@@ -0,0 +1,29 @@
+-- Emails are compared case-insensitively. Teachers or students whose emails
+-- differ only in case have to be merged by hand first; "migrate collisions"
+-- lists them.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM teachers GROUP BY lower(teacher_email) HAVING COUNT(*) > 1)
+        OR EXISTS (SELECT 1 FROM students GROUP BY lower(student_email) HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'some teacher or student emails differ only in case; run "migrate collisions" to list them';
+    END IF;
+END $$;
+
+ALTER TABLE registrations DROP CONSTRAINT registrations_teacher_email_fkey;
+ALTER TABLE registrations ADD CONSTRAINT registrations_teacher_email_fkey
+    FOREIGN KEY (teacher_email) REFERENCES teachers(teacher_email) ON UPDATE CASCADE;
+ALTER TABLE registrations DROP CONSTRAINT registrations_student_email_fkey;
+ALTER TABLE registrations ADD CONSTRAINT registrations_student_email_fkey
+    FOREIGN KEY (student_email) REFERENCES students(student_email) ON UPDATE CASCADE;
+ALTER TABLE suspensions DROP CONSTRAINT suspensions_student_email_fkey;
+ALTER TABLE suspensions ADD CONSTRAINT suspensions_student_email_fkey
+    FOREIGN KEY (student_email) REFERENCES students(student_email) ON UPDATE CASCADE;
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_teacher_email_fkey;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_teacher_email_fkey
+    FOREIGN KEY (teacher_email) REFERENCES teachers(teacher_email) ON UPDATE CASCADE ON DELETE CASCADE;
+
+UPDATE teachers SET teacher_email = lower(teacher_email) WHERE teacher_email <> lower(teacher_email);
+UPDATE students SET student_email = lower(student_email) WHERE student_email <> lower(student_email);
+
+CREATE UNIQUE INDEX IF NOT EXISTS teachers_email_lower_idx ON teachers (lower(teacher_email));
+CREATE UNIQUE INDEX IF NOT EXISTS students_email_lower_idx ON students (lower(student_email));
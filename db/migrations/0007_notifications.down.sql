@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE permission = 'notifications:read';
+
+DROP TABLE IF EXISTS notification_recipients;
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,29 @@
+-- Notifications keep the emails they were sent with, so they carry no
+-- foreign keys to teachers or students and outlive renames and deletions.
+CREATE TABLE IF NOT EXISTS notifications (
+    notification_id bigserial PRIMARY KEY,
+    teacher_email text NOT NULL,
+    message text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_teacher_email_idx ON notifications (teacher_email, created_at);
+CREATE INDEX IF NOT EXISTS notifications_created_at_idx ON notifications (created_at);
+
+CREATE TABLE IF NOT EXISTS notification_recipients (
+    notification_id bigint NOT NULL REFERENCES notifications(notification_id) ON DELETE CASCADE,
+    student_email text NOT NULL,
+    PRIMARY KEY (notification_id, student_email)
+);
+
+CREATE INDEX IF NOT EXISTS notification_recipients_student_email_idx ON notification_recipients (student_email);
+
+INSERT INTO permissions (permission, description) VALUES
+    ('notifications:read', 'View sent notifications and their recipients')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'notifications:read'),
+    ('teacher', 'notifications:read'),
+    ('auditor', 'notifications:read')
+ON CONFLICT DO NOTHING;
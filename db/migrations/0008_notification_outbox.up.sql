@@ -0,0 +1,20 @@
+-- The outbox holds one row per notification, recipient and channel. Rows are
+-- written in the same transaction as the notification and sent afterwards by
+-- the delivery workers, which claim due rows by pushing next_attempt_at past
+-- the time a send may take.
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    delivery_id bigserial PRIMARY KEY,
+    notification_id bigint NOT NULL REFERENCES notifications(notification_id) ON DELETE CASCADE,
+    channel text NOT NULL,
+    recipient text NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    delivered_at timestamptz,
+    UNIQUE (notification_id, channel, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS notification_deliveries_due_idx ON notification_deliveries (next_attempt_at)
+    WHERE status = 'pending';
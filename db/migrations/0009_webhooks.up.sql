@@ -0,0 +1,40 @@
+-- Webhooks subscribe a URL to events. The secret is kept as given because it
+-- signs every request sent to the URL.
+CREATE TABLE IF NOT EXISTS webhooks (
+    webhook_id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Webhook deliveries are queued with the notification that raised them, like
+-- notification_deliveries, and stay behind as the webhook's delivery log.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    delivery_id bigserial PRIMARY KEY,
+    webhook_id bigint NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+    event_type text NOT NULL,
+    notification_id bigint NOT NULL REFERENCES notifications(notification_id) ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    response_status integer,
+    last_error text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    delivered_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+
+INSERT INTO permissions (permission, description) VALUES
+    ('webhooks:read', 'View webhooks and their delivery logs'),
+    ('webhooks:write', 'Create and delete webhooks')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'webhooks:read'),
+    ('admin', 'webhooks:write'),
+    ('auditor', 'webhooks:read')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+-- full_at is when a bucket will have refilled completely, after which it can
+-- be deleted and recreated on demand. Existing buckets are kept for a day,
+-- longer than any sensible refill time.
+ALTER TABLE rate_limit_buckets ADD COLUMN IF NOT EXISTS full_at timestamptz;
+UPDATE rate_limit_buckets SET full_at = updated_at + interval '1 day' WHERE full_at IS NULL;
+ALTER TABLE rate_limit_buckets ALTER COLUMN full_at SET DEFAULT now();
+ALTER TABLE rate_limit_buckets ALTER COLUMN full_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
@@ -1,28 +1,66 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
-)
+	"log/slog"
+	"time"
 
-func OpenConnection() *sql.DB {
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
+	"github.com/leeshuoan/gds-OneCV/config"
+	_ "github.com/lib/pq"
+)
 
-	connStr := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", dbUser, dbPassword, dbName)
+// Delays between attempts to reach the database at startup. They are
+// variables so that tests can shorten them.
+var (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
 
-	db, err := sql.Open("postgres", connStr)
+// OpenConnection opens the connection pool and waits for the database to
+// answer, retrying with exponential backoff for up to cfg.StartupTimeout so
+// that the server survives starting before the database.
+func OpenConnection(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.ConnectionString())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	err = db.Ping()
-	if err != nil {
-		log.Fatal(err)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+
+	if err := waitForDatabase(ctx, db.PingContext, time.Duration(cfg.StartupTimeout)); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	return db
-}
\ No newline at end of file
+func waitForDatabase(ctx context.Context, ping func(context.Context) error, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("database unreachable after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("database unreachable, retrying", "attempt", attempt, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForDatabase(t *testing.T) {
+	initialBackoff, maxBackoff = time.Millisecond, 4*time.Millisecond
+	unreachable := errors.New("connection refused")
+
+	t.Run("Retries Until Reachable", func(t *testing.T) {
+		attempts := 0
+		ping := func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return unreachable
+			}
+			return nil
+		}
+
+		if err := waitForDatabase(context.Background(), ping, time.Second); err != nil {
+			t.Fatalf("Expected database to become reachable; got %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("Expected 3 attempts; got %d", attempts)
+		}
+	})
+
+	t.Run("Gives Up After Timeout", func(t *testing.T) {
+		ping := func(ctx context.Context) error { return unreachable }
+
+		err := waitForDatabase(context.Background(), ping, 20*time.Millisecond)
+		if !errors.Is(err, unreachable) {
+			t.Errorf("Expected the last ping error; got %v", err)
+		}
+	})
+
+	t.Run("Stops When Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ping := func(ctx context.Context) error { return unreachable }
+
+		err := waitForDatabase(ctx, ping, time.Minute)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v; got %v", context.Canceled, err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// Channel sends a notification to one recipient, such as by email.
+type Channel interface {
+	// Name identifies the channel's deliveries in the outbox.
+	Name() string
+	Send(ctx context.Context, delivery store.Delivery) error
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a failure that retrying cannot fix, such as a mailbox that
+// does not exist, so that the delivery is given up on at once.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// Backoff returns how long to wait after the given number of failed attempts:
+// base after the first, doubling with every further attempt up to maxDelay.
+func Backoff(attempts int, base, maxDelay time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < maxDelay; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxDelay)
+}
+
+// Pool sends the deliveries in the outbox over its channels. Every worker
+// claims one due delivery at a time, so the pool may run on several servers
+// against the same database.
+type Pool struct {
+	store    store.DeliveryStore
+	cfg      config.DeliveryConfig
+	channels map[string]Channel
+	names    []string
+
+	// Now is the clock used to schedule retries. Tests may replace it.
+	Now func() time.Time
+}
+
+func NewPool(s store.DeliveryStore, cfg config.DeliveryConfig, channels ...Channel) *Pool {
+	p := &Pool{store: s, cfg: cfg, channels: make(map[string]Channel), Now: time.Now}
+	for _, channel := range channels {
+		p.channels[channel.Name()] = channel
+		p.names = append(p.names, channel.Name())
+	}
+	return p
+}
+
+// Channels returns the names of the pool's channels, which are the channels
+// new notifications should be queued on.
+func (p *Pool) Channels() []string {
+	return append([]string{}, p.names...)
+}
+
+// Run delivers with the configured number of workers until ctx is cancelled
+// and the sends under way have finished.
+func (p *Pool) Run(ctx context.Context) {
+	runWorkers(ctx, p.cfg.Workers, time.Duration(p.cfg.PollInterval), p.DeliverNext)
+}
+
+// runWorkers calls next from each of n goroutines until ctx is cancelled.
+// A worker for which next found nothing to do waits for interval before
+// calling it again.
+func runWorkers(ctx context.Context, n int, interval time.Duration, next func(ctx context.Context) bool) {
+	var workers sync.WaitGroup
+	for i := 0; i < n; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				for ctx.Err() == nil && next(ctx) {
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// DeliverNext claims a due delivery and sends it, recording whether it was
+// delivered, will be retried or has failed. It reports false when there was
+// nothing to deliver.
+func (p *Pool) DeliverNext(ctx context.Context) bool {
+	// The claim lasts long enough for the send and recording its outcome.
+	lease := 2 * time.Duration(p.cfg.SendTimeout)
+	deliveries, err := p.store.ClaimDeliveries(ctx, p.names, 1, lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("claiming notification deliveries", "err", err)
+		}
+		return false
+	}
+	if len(deliveries) == 0 {
+		return false
+	}
+
+	// A send that has started may finish during shutdown, so that it is
+	// neither cut off halfway nor sent again once its claim runs out.
+	p.deliver(context.WithoutCancel(ctx), deliveries[0])
+	return true
+}
+
+func (p *Pool) deliver(ctx context.Context, d store.Delivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, time.Duration(p.cfg.SendTimeout))
+	sendErr := p.channels[d.Channel].Send(sendCtx, d)
+	cancel()
+
+	var err error
+	switch {
+	case sendErr == nil:
+		err = p.store.CompleteDelivery(ctx, d.ID)
+	case IsPermanent(sendErr) || d.Attempts >= p.cfg.MaxAttempts:
+		slog.Error("giving up on notification delivery",
+			"notificationId", d.NotificationID, "recipient", d.Recipient, "channel", d.Channel, "attempts", d.Attempts, "err", sendErr)
+		err = p.store.FailDelivery(ctx, d.ID, sendErr.Error())
+	default:
+		retryAt := p.Now().Add(Backoff(d.Attempts, time.Duration(p.cfg.RetryBackoff), time.Duration(p.cfg.MaxRetryBackoff)))
+		err = p.store.RetryDelivery(ctx, d.ID, sendErr.Error(), retryAt)
+	}
+	if err != nil {
+		slog.Error("recording delivery", "deliveryId", d.ID,
+			"notificationId", d.NotificationID, "recipient", d.Recipient, "channel", d.Channel, "attempts", d.Attempts, "err", err)
+	}
+}
@@ -0,0 +1,188 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+func newStore(t *testing.T, students ...string) *store.Memory {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.CreateTeacher(ctx, "teacherken@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.CreateStudents(ctx, students); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RegisterStudents(ctx, "teacherken@gmail.com", students, false); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testConfig() config.DeliveryConfig {
+	cfg := config.Default().Delivery
+	cfg.Workers = 2
+	cfg.PollInterval = config.Duration(10 * time.Millisecond)
+	cfg.SendTimeout = config.Duration(5 * time.Second)
+	cfg.MaxAttempts = 3
+	cfg.RetryBackoff = config.Duration(time.Minute)
+	cfg.MaxRetryBackoff = config.Duration(time.Hour)
+	return cfg
+}
+
+// flakyChannel fails every send with the errors it is given, in order, and
+// succeeds once they run out.
+type flakyChannel struct {
+	mu     sync.Mutex
+	errs   []error
+	sends  int
+	failed int
+}
+
+func (c *flakyChannel) Name() string {
+	return "flaky"
+}
+
+func (c *flakyChannel) Send(ctx context.Context, d store.Delivery) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sends++
+	if c.failed < len(c.errs) {
+		c.failed++
+		return c.errs[c.failed-1]
+	}
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{
+		1: 30 * time.Second,
+		2: time.Minute,
+		4: 4 * time.Minute,
+		9: 5 * time.Minute,
+	} {
+		if got := Backoff(attempts, 30*time.Second, 5*time.Minute); got != expected {
+			t.Errorf("Expected backoff %s after %d attempts; got %s", expected, attempts, got)
+		}
+	}
+}
+
+func TestPoolDeliversByEmail(t *testing.T) {
+	server := newFakeSMTP(t)
+	s := newStore(t, "studentagnes@gmail.com", "studentbob@gmail.com")
+	pool := NewPool(s, testConfig(), NewSMTP(server.config()))
+
+	notification, err := s.CreateNotification(context.Background(), "teacherken@gmail.com", "Exam on Monday", nil, pool.Channels())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.received()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-stopped
+
+	received := make(map[string]bool)
+	for _, message := range server.received() {
+		received[message.to[0]] = true
+	}
+	if len(received) != 2 || !received["studentagnes@gmail.com"] || !received["studentbob@gmail.com"] {
+		t.Fatalf("Expected one email to each student; got %+v", server.received())
+	}
+
+	deliveries, err := s.ListDeliveries(context.Background(), notification.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deliveries {
+		if d.Channel != ChannelEmail || d.Status != store.DeliveryDelivered || d.Attempts != 1 || d.DeliveredAt == nil {
+			t.Errorf("Expected %s to be delivered by email on the first attempt; got %+v", d.Recipient, d)
+		}
+	}
+}
+
+func TestPoolRetries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	setup := func(t *testing.T, errs ...error) (*store.Memory, *Pool, *flakyChannel) {
+		s := newStore(t, "studentagnes@gmail.com")
+		s.Now = clock
+		channel := &flakyChannel{errs: errs}
+		pool := NewPool(s, testConfig(), channel)
+		pool.Now = clock
+		if _, err := s.CreateNotification(ctx, "teacherken@gmail.com", "Exam on Monday", nil, pool.Channels()); err != nil {
+			t.Fatal(err)
+		}
+		return s, pool, channel
+	}
+
+	t.Run("Temporary Failure", func(t *testing.T) {
+		s, pool, channel := setup(t, errors.New("connection refused"))
+
+		if !pool.DeliverNext(ctx) {
+			t.Fatal("Expected a delivery to be due")
+		}
+		if pool.DeliverNext(ctx) {
+			t.Error("Expected the failed delivery to wait for its backoff")
+		}
+
+		now = now.Add(time.Minute)
+		if !pool.DeliverNext(ctx) {
+			t.Fatal("Expected the delivery to be retried after its backoff")
+		}
+
+		deliveries, _ := s.ListDeliveries(ctx, 1)
+		if channel.sends != 2 || deliveries[0].Status != store.DeliveryDelivered || deliveries[0].Attempts != 2 {
+			t.Errorf("Expected delivery on the second attempt; got %d sends and %+v", channel.sends, deliveries[0])
+		}
+	})
+
+	t.Run("Too Many Attempts", func(t *testing.T) {
+		refused := errors.New("connection refused")
+		s, pool, channel := setup(t, refused, refused, refused, refused)
+
+		for i := 0; i < 5; i++ {
+			pool.DeliverNext(ctx)
+			now = now.Add(time.Hour)
+		}
+
+		deliveries, _ := s.ListDeliveries(ctx, 1)
+		if channel.sends != 3 || deliveries[0].Status != store.DeliveryFailed || deliveries[0].LastError != "connection refused" {
+			t.Errorf("Expected the delivery to fail after 3 attempts; got %d sends and %+v", channel.sends, deliveries[0])
+		}
+	})
+
+	t.Run("Permanent Failure", func(t *testing.T) {
+		s, pool, channel := setup(t, Permanent(errors.New("550 No such user")))
+
+		pool.DeliverNext(ctx)
+		now = now.Add(time.Hour)
+		if pool.DeliverNext(ctx) {
+			t.Error("Expected a permanent failure not to be retried")
+		}
+
+		deliveries, _ := s.ListDeliveries(ctx, 1)
+		if channel.sends != 1 || deliveries[0].Status != store.DeliveryFailed {
+			t.Errorf("Expected the delivery to fail at once; got %d sends and %+v", channel.sends, deliveries[0])
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+const ChannelEmail = "email"
+
+// SMTP emails notifications through a mail server.
+type SMTP struct {
+	cfg config.SMTPConfig
+
+	// TLSConfig is used when the server offers STARTTLS. Tests may replace
+	// it.
+	TLSConfig *tls.Config
+}
+
+func NewSMTP(cfg config.SMTPConfig) *SMTP {
+	return &SMTP{cfg: cfg, TLSConfig: &tls.Config{ServerName: cfg.Host}}
+}
+
+func (s *SMTP) Name() string {
+	return ChannelEmail
+}
+
+func (s *SMTP) Send(ctx context.Context, d store.Delivery) error {
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("smtp from address: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(s.TLSConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	// The server's verdict on this recipient or message holds for every
+	// retry, unlike a temporary 4xx reply.
+	if err := client.Rcpt(d.Recipient); err != nil {
+		return rejected(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return rejected(err)
+	}
+	if _, err := w.Write(s.message(from, d)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return rejected(err)
+	}
+	return client.Quit()
+}
+
+func rejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (s *SMTP) message(from *mail.Address, d store.Delivery) []byte {
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", d.Recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "New notification from "+d.Teacher))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <notification-%d-%d@%s>\r\n", d.NotificationID, d.ID, domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&b)
+	body.Write([]byte(d.Message))
+	body.Close()
+	return b.Bytes()
+}
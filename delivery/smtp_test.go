@@ -0,0 +1,180 @@
+package delivery
+
+import (
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+type fakeMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is a mail server that accepts every message, except for the
+// recipients it is told to reject, and keeps them for inspection.
+type fakeSMTP struct {
+	listener net.Listener
+	reject   map[string]string
+
+	mu       sync.Mutex
+	messages []fakeMessage
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{listener: listener, reject: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) config() config.SMTPConfig {
+	addr := f.listener.Addr().(*net.TCPAddr)
+	return config.SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: "OneCV <notifications@school.edu>"}
+}
+
+func (f *fakeSMTP) received() []fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeMessage{}, f.messages...)
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	c := textproto.NewConn(conn)
+	defer c.Close()
+
+	c.PrintfLine("220 fake ESMTP")
+	var message fakeMessage
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		address, _, _ := strings.Cut(arg[strings.Index(arg, "<")+1:], ">")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-fake")
+			c.PrintfLine("250 8BITMIME")
+		case "HELO", "NOOP":
+			c.PrintfLine("250 OK")
+		case "RSET":
+			message = fakeMessage{}
+			c.PrintfLine("250 OK")
+		case "MAIL":
+			message = fakeMessage{from: address}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			if reply, ok := f.reject[address]; ok {
+				c.PrintfLine("%s", reply)
+				continue
+			}
+			message.to = append(message.to, address)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 Go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.data = string(data)
+			f.mu.Lock()
+			f.messages = append(f.messages, message)
+			f.mu.Unlock()
+			c.PrintfLine("250 Queued")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Unknown command")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	server := newFakeSMTP(t)
+	channel := NewSMTP(server.config())
+
+	d := store.Delivery{
+		ID:             3,
+		NotificationID: 7,
+		Recipient:      "studentagnes@gmail.com",
+		Teacher:        "teacherken@gmail.com",
+		Message:        "Exam on Monday\n.\nBring a pencil ✏️",
+	}
+	if err := channel.Send(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message; got %d", len(messages))
+	}
+	if messages[0].from != "notifications@school.edu" || strings.Join(messages[0].to, ",") != "studentagnes@gmail.com" {
+		t.Errorf("Expected a message from notifications@school.edu to studentagnes@gmail.com; got %+v", messages[0])
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(messages[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("Subject"); got != "New notification from teacherken@gmail.com" {
+		t.Errorf("Unexpected subject %q", got)
+	}
+	if got := parsed.Header.Get("Message-ID"); got != "<notification-7-3@school.edu>" {
+		t.Errorf("Unexpected Message-ID %q", got)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n"); got != d.Message {
+		t.Errorf("Expected body %q; got %q", d.Message, got)
+	}
+}
+
+func TestSMTPRejectedRecipient(t *testing.T) {
+	server := newFakeSMTP(t)
+	server.reject["unknown@gmail.com"] = "550 No such user"
+	server.reject["full@gmail.com"] = "452 Mailbox full"
+	channel := NewSMTP(server.config())
+
+	for recipient, permanent := range map[string]bool{"unknown@gmail.com": true, "full@gmail.com": false} {
+		err := channel.Send(context.Background(), store.Delivery{Recipient: recipient, Message: "Hello"})
+		if err == nil {
+			t.Fatalf("Expected sending to %s to fail", recipient)
+		}
+		if IsPermanent(err) != permanent {
+			t.Errorf("Expected failure for %s to be permanent %s; got %v", recipient, strconv.FormatBool(permanent), err)
+		}
+	}
+	if messages := server.received(); len(messages) != 0 {
+		t.Errorf("Expected no messages; got %+v", messages)
+	}
+}
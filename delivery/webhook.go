@@ -0,0 +1,216 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+const (
+	// SignatureHeader carries the signature made by Sign.
+	SignatureHeader = "X-OneCV-Signature"
+	EventHeader     = "X-OneCV-Event"
+	// DeliveryHeader repeats the event's ID, which stays the same across
+	// retries so that receivers can ignore duplicates.
+	DeliveryHeader = "X-OneCV-Delivery"
+)
+
+// Event is the body of a webhook request.
+type Event struct {
+	ID        int64               `json:"id"`
+	Type      string              `json:"type"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Data      models.Notification `json:"data"`
+}
+
+// Sign returns the signature of a webhook request body sent at timestamp, in
+// the form "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">". The
+// timestamp is signed too, so that receivers can reject replayed requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), signature(secret, timestamp.Unix(), body))
+}
+
+func signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature made by Sign, rejecting it if it was made more
+// than tolerance before now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return errors.New("malformed webhook signature")
+	}
+	if now.Sub(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return errors.New("webhook signature timestamp is outside the tolerance")
+	}
+
+	expected := signature(secret, timestamp, body)
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("webhook signature does not match")
+}
+
+// Webhooks sends the events queued for webhooks as signed POST requests.
+type Webhooks struct {
+	store  store.Store
+	cfg    config.WebhookConfig
+	client *http.Client
+
+	// Now is the clock used to sign requests and schedule retries. Tests
+	// may replace it.
+	Now func() time.Time
+}
+
+func NewWebhooks(s store.Store, cfg config.WebhookConfig) *Webhooks {
+	return &Webhooks{
+		store: s,
+		cfg:   cfg,
+		client: &http.Client{
+			// A redirect is reported as a failure rather than followed, since
+			// the receiver is expected to live at the subscribed URL.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		Now: time.Now,
+	}
+}
+
+// Run sends events with the configured number of workers until ctx is
+// cancelled and the requests under way have finished.
+func (w *Webhooks) Run(ctx context.Context) {
+	runWorkers(ctx, w.cfg.Workers, time.Duration(w.cfg.PollInterval), w.DeliverNext)
+}
+
+// DeliverNext claims a due webhook delivery and sends it, recording the
+// outcome in the delivery log. It reports false when there was nothing to
+// deliver.
+func (w *Webhooks) DeliverNext(ctx context.Context) bool {
+	lease := 2 * time.Duration(w.cfg.SendTimeout)
+	deliveries, err := w.store.ClaimWebhookDeliveries(ctx, 1, lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("claiming webhook deliveries", "err", err)
+		}
+		return false
+	}
+	if len(deliveries) == 0 {
+		return false
+	}
+
+	w.deliver(context.WithoutCancel(ctx), deliveries[0])
+	return true
+}
+
+func (w *Webhooks) deliver(ctx context.Context, d store.WebhookDelivery) {
+	status, sendErr := w.send(ctx, d)
+
+	var err error
+	switch {
+	case errors.Is(sendErr, store.ErrNotFound):
+		// The webhook or notification was deleted, taking the delivery
+		// with it.
+		return
+	case sendErr == nil:
+		err = w.store.CompleteWebhookDelivery(ctx, d.ID, status)
+	case IsPermanent(sendErr) || d.Attempts >= w.cfg.MaxAttempts:
+		slog.Error("giving up on webhook delivery", "deliveryId", d.ID, "webhookId", d.WebhookID,
+			"event", d.EventType, "notificationId", d.NotificationID, "status", status, "attempts", d.Attempts, "err", sendErr)
+		err = w.store.FailWebhookDelivery(ctx, d.ID, status, sendErr.Error())
+	default:
+		retryAt := w.Now().Add(Backoff(d.Attempts, time.Duration(w.cfg.RetryBackoff), time.Duration(w.cfg.MaxRetryBackoff)))
+		err = w.store.RetryWebhookDelivery(ctx, d.ID, status, sendErr.Error(), retryAt)
+	}
+	if err != nil {
+		slog.Error("recording webhook delivery", "deliveryId", d.ID, "webhookId", d.WebhookID,
+			"event", d.EventType, "notificationId", d.NotificationID, "attempts", d.Attempts, "err", err)
+	}
+}
+
+// send posts the event and returns the response status, or 0 if no
+// response arrived. Requests that the receiver rejects with a status other
+// than 408, 429 or 5xx are not retried.
+func (w *Webhooks) send(ctx context.Context, d store.WebhookDelivery) (int, error) {
+	webhook, err := w.store.GetWebhook(ctx, d.WebhookID)
+	if err != nil {
+		return 0, err
+	}
+	notification, err := w.store.GetNotification(ctx, d.NotificationID)
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := json.Marshal(Event{
+		ID:        d.ID,
+		Type:      d.EventType,
+		CreatedAt: d.CreatedAt,
+		Data: models.Notification{
+			ID:             notification.ID,
+			Teacher:        notification.Teacher,
+			Notification:   notification.Message,
+			CreatedAt:      notification.CreatedAt,
+			RecipientCount: notification.RecipientCount,
+			Recipients:     notification.Recipients,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(w.cfg.SendTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gds-OneCV-webhooks")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, w.Now(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, Permanent(fmt.Errorf("receiver responded %s", resp.Status))
+}
@@ -0,0 +1,222 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+const testSecret = "a-very-long-webhook-secret"
+
+// receiver is a webhook endpoint that verifies each request's signature and
+// answers with the given statuses in order, then with 204.
+type receiver struct {
+	*httptest.Server
+	now func() time.Time
+
+	mu       sync.Mutex
+	statuses []int
+	events   []Event
+	headers  []http.Header
+	invalid  []error
+}
+
+func newReceiver(t *testing.T, now func() time.Time, statuses ...int) *receiver {
+	r := &receiver{now: now, statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := Verify(testSecret, req.Header.Get(SignatureHeader), body, 5*time.Minute, r.now()); err != nil {
+		r.invalid = append(r.invalid, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event Event
+	json.Unmarshal(body, &event)
+	r.events = append(r.events, event)
+	r.headers = append(r.headers, req.Header.Clone())
+
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func webhookConfig() config.WebhookConfig {
+	cfg := config.Default().Webhooks
+	cfg.MaxAttempts = 3
+	cfg.RetryBackoff = config.Duration(time.Minute)
+	cfg.MaxRetryBackoff = config.Duration(time.Hour)
+	return cfg
+}
+
+func TestSignAndVerify(t *testing.T) {
+	sent := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":1}`)
+	header := Sign(testSecret, sent, body)
+
+	if !strings.HasPrefix(header, "t=1709283600,v1=") {
+		t.Errorf("Unexpected signature header %s", header)
+	}
+	if err := Verify(testSecret, header, body, time.Minute, sent.Add(30*time.Second)); err != nil {
+		t.Errorf("Expected the signature to verify; got %v", err)
+	}
+	for name, err := range map[string]error{
+		"Tampered Body": Verify(testSecret, header, []byte(`{"id":2}`), time.Minute, sent),
+		"Wrong Secret":  Verify("another-secret", header, body, time.Minute, sent),
+		"Replayed":      Verify(testSecret, header, body, time.Minute, sent.Add(2*time.Minute)),
+		"Malformed":     Verify(testSecret, "v1=abc", body, time.Minute, sent),
+	} {
+		if err == nil {
+			t.Errorf("%s: expected the signature to be rejected", name)
+		}
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	setup := func(t *testing.T, statuses ...int) (*store.Memory, *Webhooks, *receiver, store.Webhook) {
+		s := newStore(t, "studentagnes@gmail.com", "studentbob@gmail.com")
+		s.Now = clock
+		r := newReceiver(t, clock, statuses...)
+		webhook, err := s.CreateWebhook(ctx, store.Webhook{URL: r.URL + "/hooks", Secret: testSecret, EventTypes: []string{store.EventNotificationSent}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateNotification(ctx, "teacherken@gmail.com", "Exam on Monday", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		webhooks := NewWebhooks(s, webhookConfig())
+		webhooks.Now = clock
+		return s, webhooks, r, webhook
+	}
+
+	log := func(t *testing.T, s *store.Memory, webhook store.Webhook) store.WebhookDelivery {
+		t.Helper()
+		deliveries, _, err := s.ListWebhookDeliveries(ctx, webhook.ID, 10, 0)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("Expected 1 delivery; got %+v, %v", deliveries, err)
+		}
+		return deliveries[0]
+	}
+
+	t.Run("Signed Event", func(t *testing.T) {
+		s, webhooks, r, webhook := setup(t)
+
+		if !webhooks.DeliverNext(ctx) {
+			t.Fatal("Expected a webhook delivery to be due")
+		}
+		if len(r.invalid) > 0 || len(r.events) != 1 {
+			t.Fatalf("Expected 1 validly signed event; got %d events and %v", len(r.events), r.invalid)
+		}
+
+		event := r.events[0]
+		if event.Type != store.EventNotificationSent || event.Data.Teacher != "teacherken@gmail.com" ||
+			event.Data.Notification != "Exam on Monday" ||
+			strings.Join(event.Data.Recipients, ",") != "studentagnes@gmail.com,studentbob@gmail.com" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if r.headers[0].Get(EventHeader) != store.EventNotificationSent || r.headers[0].Get(DeliveryHeader) != "1" {
+			t.Errorf("Unexpected headers %v", r.headers[0])
+		}
+
+		d := log(t, s, webhook)
+		if d.Status != store.DeliveryDelivered || d.Attempts != 1 || d.ResponseStatus != http.StatusNoContent || d.DeliveredAt == nil {
+			t.Errorf("Expected the delivery log to show delivery on the first attempt; got %+v", d)
+		}
+	})
+
+	t.Run("Retries With Backoff", func(t *testing.T) {
+		s, webhooks, r, webhook := setup(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+		webhooks.DeliverNext(ctx)
+		now = now.Add(59 * time.Second)
+		if webhooks.DeliverNext(ctx) {
+			t.Error("Expected the first retry to wait a minute")
+		}
+		now = now.Add(time.Second)
+		webhooks.DeliverNext(ctx)
+
+		d := log(t, s, webhook)
+		if d.Status != store.DeliveryPending || d.ResponseStatus != http.StatusServiceUnavailable || d.LastError != "receiver responded 503 Service Unavailable" {
+			t.Errorf("Expected the failed attempts in the delivery log; got %+v", d)
+		}
+
+		now = now.Add(time.Minute)
+		if webhooks.DeliverNext(ctx) {
+			t.Error("Expected the second retry to wait two minutes")
+		}
+		now = now.Add(time.Minute)
+		webhooks.DeliverNext(ctx)
+
+		d = log(t, s, webhook)
+		if len(r.events) != 3 || d.Status != store.DeliveryDelivered || d.Attempts != 3 || d.LastError != "" {
+			t.Errorf("Expected delivery on the third attempt; got %d requests and %+v", len(r.events), d)
+		}
+		for _, event := range r.events {
+			if event.ID != r.events[0].ID {
+				t.Errorf("Expected retries to keep the event ID %d; got %d", r.events[0].ID, event.ID)
+			}
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		s, webhooks, r, webhook := setup(t, http.StatusGone)
+
+		webhooks.DeliverNext(ctx)
+		now = now.Add(time.Hour)
+		if webhooks.DeliverNext(ctx) {
+			t.Error("Expected a rejected event not to be retried")
+		}
+
+		d := log(t, s, webhook)
+		if len(r.events) != 1 || d.Status != store.DeliveryFailed || d.ResponseStatus != http.StatusGone {
+			t.Errorf("Expected the delivery to fail at once; got %+v", d)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		s, webhooks, r, webhook := setup(t)
+		r.Close()
+
+		for i := 0; i < 3; i++ {
+			webhooks.DeliverNext(ctx)
+			now = now.Add(time.Hour)
+		}
+
+		d := log(t, s, webhook)
+		if d.Status != store.DeliveryFailed || d.Attempts != 3 || d.ResponseStatus != 0 || d.LastError == "" {
+			t.Errorf("Expected the delivery to fail after 3 attempts without a response; got %+v", d)
+		}
+	})
+
+	t.Run("Not Subscribed", func(t *testing.T) {
+		s := newStore(t, "studentagnes@gmail.com")
+		webhook, _ := s.CreateWebhook(ctx, store.Webhook{URL: "http://localhost", Secret: testSecret, EventTypes: []string{"student.suspended"}})
+		s.CreateNotification(ctx, "teacherken@gmail.com", "Exam on Monday", nil, nil)
+
+		if deliveries, total, _ := s.ListWebhookDeliveries(ctx, webhook.ID, 10, 0); total != 0 {
+			t.Errorf("Expected no deliveries; got %+v", deliveries)
+		}
+	})
+}
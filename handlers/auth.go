@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// authorizeTeacher reports whether the caller may act as teacher, and
+// otherwise writes the error response.
+func authorizeTeacher(w http.ResponseWriter, r *http.Request, teacher string) bool {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.SendJSONError(w, http.StatusUnauthorized, "Authentication is required")
+		return false
+	}
+	if !identity.CanActFor(teacher) {
+		utils.SendJSONError(w, http.StatusForbidden, fmt.Sprintf("The API key cannot act for teacher %s", teacher))
+		return false
+	}
+	return true
+}
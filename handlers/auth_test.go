@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+func TestAuthorization(t *testing.T) {
+	s := newSeededStore(t)
+
+	tests := []struct {
+		name           string
+		handler        func(w http.ResponseWriter, r *http.Request, s store.Store)
+		body           string
+		as             func(req *http.Request) *http.Request
+		expectedStatus int
+	}{
+		{
+			"Register Without Identity", Register,
+			`{"teacher": "teacherken@gmail.com", "students": ["studentmary@gmail.com"]}`,
+			func(req *http.Request) *http.Request { return req },
+			http.StatusUnauthorized,
+		},
+		{
+			"Register For Another Teacher", Register,
+			`{"teacher": "teacherken@gmail.com", "students": ["studentmary@gmail.com"]}`,
+			func(req *http.Request) *http.Request { return asTeacher(req, "teacherjoe@gmail.com") },
+			http.StatusForbidden,
+		},
+		{
+			"Deregister For Another Teacher", Deregister,
+			`{"teacher": "teacherken@gmail.com", "all": true}`,
+			func(req *http.Request) *http.Request { return asTeacher(req, "teacherjoe@gmail.com") },
+			http.StatusForbidden,
+		},
+		{
+			"Notify As Another Teacher", func(w http.ResponseWriter, r *http.Request, s store.Store) {
+				RetrieveForNotifications(w, r, s, nil)
+			},
+			`{"teacher": "teacherken@gmail.com", "notification": "Hello"}`,
+			func(req *http.Request) *http.Request { return asTeacher(req, "teacherjoe@gmail.com") },
+			http.StatusForbidden,
+		},
+		{
+			"Admin Registers For Any Teacher", Register,
+			`{"teacher": "teacherjoe@gmail.com", "students": ["studentagnes@gmail.com"]}`,
+			asAdmin,
+			http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.as(httptest.NewRequest("POST", "/", strings.NewReader(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req, s)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d; got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+
+	t.Run("Rejected Requests Change Nothing", func(t *testing.T) {
+		teacher, err := s.GetTeacher(context.Background(), "teacherken@gmail.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, student := range teacher.Students {
+			if student == "studentmary@gmail.com" {
+				t.Error("Expected studentmary@gmail.com not to be registered to teacherken@gmail.com")
+			}
+		}
+		if len(teacher.Students) != 3 {
+			t.Errorf("Expected teacherken@gmail.com to keep 3 students; got %d", len(teacher.Students))
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// maxBodyBytes bounds request bodies. The largest legitimate body is a bulk
+// student import, which fits comfortably.
+const maxBodyBytes = 1 << 20
+
+// decodeRequest reads a JSON request body into request and validates it. It
+// writes the error response and returns false if the body is not exactly one
+// valid JSON object of the expected shape.
+func decodeRequest(w http.ResponseWriter, r *http.Request, request models.Validator) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		utils.SendJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(request); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.SendJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+			return false
+		}
+		utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{decodeError(err)})
+		return false
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{{Field: "body", Message: "must contain a single JSON object"}})
+		return false
+	}
+
+	if errs := request.Validate(); len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return false
+	}
+	return true
+}
+
+// decodeError turns a JSON decoding error into a field error that does not
+// leak Go type names.
+func decodeError(err error) models.FieldError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return models.FieldError{Field: "body", Message: "must not be empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return models.FieldError{Field: "body", Message: "is not valid JSON"}
+	case errors.As(err, &syntaxErr):
+		return models.FieldError{Field: "body", Message: fmt.Sprintf("is not valid JSON at offset %d", syntaxErr.Offset)}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return models.FieldError{Field: field, Message: "must be " + describeType(typeErr.Type)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return models.FieldError{Field: field, Message: "is not a known field"}
+	}
+	return models.FieldError{Field: "body", Message: "could not be read"}
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array of " + strings.TrimPrefix(strings.TrimPrefix(describeType(t.Elem()), "a "), "an ") + "s"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "a different type"
+}
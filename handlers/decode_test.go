@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedErrors []models.FieldError
+	}{
+		{
+			"Valid Request", "application/json; charset=utf-8",
+			`{"teacher": "teacherken@gmail.com", "students": ["studentjon@gmail.com"]}`,
+			http.StatusOK, nil,
+		},
+		{
+			"Wrong Content Type", "text/plain",
+			`{"teacher": "teacherken@gmail.com", "students": ["studentjon@gmail.com"]}`,
+			http.StatusUnsupportedMediaType, nil,
+		},
+		{
+			"Body Too Large", "application/json",
+			`{"teacher": "` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			http.StatusRequestEntityTooLarge, nil,
+		},
+		{
+			"Empty Body", "application/json", ``,
+			http.StatusBadRequest, []models.FieldError{{Field: "body", Message: "must not be empty"}},
+		},
+		{
+			"Malformed JSON", "application/json", `{"teacher": }`,
+			http.StatusBadRequest, []models.FieldError{{Field: "body", Message: "is not valid JSON at offset 13"}},
+		},
+		{
+			"Unknown Field", "application/json",
+			`{"teacher": "teacherken@gmail.com", "students": ["studentjon@gmail.com"], "student": "studenthon@gmail.com"}`,
+			http.StatusBadRequest, []models.FieldError{{Field: "student", Message: "is not a known field"}},
+		},
+		{
+			"Wrong Type", "application/json",
+			`{"teacher": "teacherken@gmail.com", "students": "studentjon@gmail.com"}`,
+			http.StatusBadRequest, []models.FieldError{{Field: "students", Message: "must be an array of strings"}},
+		},
+		{
+			"Trailing Data", "application/json",
+			`{"teacher": "teacherken@gmail.com", "students": ["studentjon@gmail.com"]} {}`,
+			http.StatusBadRequest, []models.FieldError{{Field: "body", Message: "must contain a single JSON object"}},
+		},
+		{
+			"Invalid Fields", "application/json",
+			`{"teacher": "", "students": ["studentjon@gmail.com", "", "not-an-email"]}`,
+			http.StatusBadRequest, []models.FieldError{
+				{Field: "teacher", Message: "is required"},
+				{Field: "students[1]", Message: "is required"},
+				{Field: "students[2]", Message: "not a valid email"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/register", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			var request models.RegistrationRequest
+			if decodeRequest(rr, req, &request) {
+				rr.WriteHeader(http.StatusOK)
+			}
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d; got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedErrors == nil {
+				return
+			}
+
+			var response struct {
+				Errors []models.FieldError `json:"errors"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(response.Errors, tt.expectedErrors) {
+				t.Errorf("Expected errors %v; got %v", tt.expectedErrors, response.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// sendStoreError reports a failed store call. Errors about a teacher, student
+// or other entity are the caller's to fix, and name the email concerned where
+// there is one; any
+// other error is a database failure, which is logged and reported as 500
+// without its details.
+func sendStoreError(w http.ResponseWriter, err error) {
+	var storeErr *store.Error
+	if !errors.As(err, &storeErr) {
+		slog.Error("store call failed", "requestId", w.Header().Get(utils.RequestIDHeader), "err", err)
+		utils.SendProblem(w, utils.Problem{
+			Status: http.StatusInternalServerError,
+			Detail: "The request could not be completed",
+		})
+		return
+	}
+
+	problem := utils.Problem{
+		Status: storeErrorStatus(storeErr),
+		Code:   storeErrorCode(storeErr),
+		Detail: storeErrorMessage(storeErr),
+	}
+	switch storeErr.Entity {
+	case store.EntityTeacher, store.EntityStudent, store.EntityRegistration:
+		problem.Email = storeErr.Email
+	}
+	utils.SendProblem(w, problem)
+}
+
+// storeErrorStatus reports an unknown entity as 404 and a duplicate or an
+// entity that is still referenced as 409.
+func storeErrorStatus(err *store.Error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrAlreadyExists), errors.Is(err, store.ErrInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// storeErrorCode combines the entity and the failure, as in
+// "student_not_found" or "registration_already_exists".
+func storeErrorCode(err *store.Error) string {
+	entity := strings.ReplaceAll(err.Entity, " ", "_")
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return entity + "_not_found"
+	case errors.Is(err, store.ErrAlreadyExists):
+		return entity + "_already_exists"
+	case errors.Is(err, store.ErrInUse):
+		return entity + "_in_use"
+	}
+	return utils.CodeInternal
+}
+
+func storeErrorMessage(err *store.Error) string {
+	switch {
+	case err.Entity == store.EntityRegistration && errors.Is(err, store.ErrAlreadyExists):
+		return fmt.Sprintf("%s is already registered with this teacher", err.Email)
+	case err.Entity == store.EntityTeacher && errors.Is(err, store.ErrNotFound):
+		return fmt.Sprintf("Teacher %s does not exist in the database", err.Email)
+	case err.Entity == store.EntityStudent && errors.Is(err, store.ErrNotFound):
+		return fmt.Sprintf("Student %s does not exist in the database", err.Email)
+	case err.Entity == store.EntityTeacher && errors.Is(err, store.ErrAlreadyExists):
+		return fmt.Sprintf("Teacher %s already exists in the database", err.Email)
+	case err.Entity == store.EntityStudent && errors.Is(err, store.ErrAlreadyExists):
+		return fmt.Sprintf("Student %s already exists in the database", err.Email)
+	case err.Entity == store.EntityStudent && errors.Is(err, store.ErrInUse):
+		return fmt.Sprintf("Student %s is still registered to teachers; use registrations=remove to delete the registrations too", err.Email)
+	case err.Entity == store.EntityNotification && errors.Is(err, store.ErrNotFound):
+		return fmt.Sprintf("Notification %s does not exist", err.Email)
+	case err.Entity == store.EntityWebhook && errors.Is(err, store.ErrNotFound):
+		return fmt.Sprintf("Webhook %s does not exist", err.Email)
+	case err.Entity == store.EntityTeacher && errors.Is(err, store.ErrInUse):
+		return fmt.Sprintf("Teacher %s still has registered students; use registrations=remove to delete them too", err.Email)
+	}
+	return err.Error()
+}
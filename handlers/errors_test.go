@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+func TestSendStoreError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedEmail  string
+	}{
+		{
+			"Unknown Student",
+			&store.Error{Entity: store.EntityStudent, Email: "studentjon@gmail.com", Err: store.ErrNotFound},
+			http.StatusNotFound, "student_not_found", "studentjon@gmail.com",
+		},
+		{
+			"Duplicate Registration",
+			&store.Error{Entity: store.EntityRegistration, Email: "studentjon@gmail.com", Err: store.ErrAlreadyExists},
+			http.StatusConflict, "registration_already_exists", "studentjon@gmail.com",
+		},
+		{
+			"Teacher In Use",
+			&store.Error{Entity: store.EntityTeacher, Email: "teacherken@gmail.com", Err: store.ErrInUse},
+			http.StatusConflict, "teacher_in_use", "teacherken@gmail.com",
+		},
+		{
+			"Database Failure",
+			errors.New("pq: connection refused"),
+			http.StatusInternalServerError, utils.CodeInternal, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			sendStoreError(rr, tt.err)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d; got %d", tt.expectedStatus, rr.Code)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != utils.ProblemContentType {
+				t.Errorf("Expected Content-Type %s; got %s", utils.ProblemContentType, contentType)
+			}
+
+			var problem utils.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Code != tt.expectedCode || problem.Type != "urn:gds-onecv:problem:"+tt.expectedCode {
+				t.Errorf("Expected code %s; got code %s and type %s", tt.expectedCode, problem.Code, problem.Type)
+			}
+			if problem.Status != tt.expectedStatus {
+				t.Errorf("Expected status member %d; got %d", tt.expectedStatus, problem.Status)
+			}
+			if problem.Email != tt.expectedEmail {
+				t.Errorf("Expected email %q; got %q", tt.expectedEmail, problem.Email)
+			}
+			if tt.expectedStatus == http.StatusInternalServerError && problem.Detail == tt.err.Error() {
+				t.Error("Expected the database error not to be exposed")
+			}
+		})
+	}
+}
+
+func TestSendStoreErrorLogsRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set(utils.RequestIDHeader, "abc123")
+	sendStoreError(rr, errors.New("pq: connection refused"))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line; got %q", logs.String())
+	}
+	if entry["level"] != "ERROR" || entry["requestId"] != "abc123" || entry["err"] != "pq: connection refused" {
+		t.Errorf("Expected an error logged with the request ID; got %v", entry)
+	}
+}
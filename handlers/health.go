@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/db"
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+// Healthz reports that the process is up and serving requests.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.HealthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the server can handle traffic: the database must
+// answer within timeout and have every embedded migration applied.
+func Readyz(w http.ResponseWriter, r *http.Request, conn *sql.DB, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	response := models.HealthResponse{Status: "ready", Checks: map[string]models.HealthCheck{}}
+
+	start := time.Now()
+	database := models.HealthCheck{Status: "ok"}
+	if err := conn.PingContext(ctx); err != nil {
+		database = models.HealthCheck{Status: "failed", Error: err.Error()}
+	}
+	database.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	response.Checks["database"] = database
+
+	response.Checks["migrations"] = checkMigrations(ctx, conn)
+
+	status := http.StatusOK
+	for _, check := range response.Checks {
+		if check.Status != "ok" {
+			response.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func checkMigrations(ctx context.Context, conn *sql.DB) models.HealthCheck {
+	expected, err := db.LatestVersion()
+	if err != nil {
+		return models.HealthCheck{Status: "failed", Error: err.Error()}
+	}
+	current, err := db.CurrentVersion(ctx, conn)
+	if err != nil {
+		return models.HealthCheck{Status: "failed", Error: err.Error(), Expected: &expected}
+	}
+
+	check := models.HealthCheck{Status: "ok", Current: &current, Expected: &expected}
+	if current != expected {
+		check.Status = "failed"
+		check.Error = fmt.Sprintf("database is at migration %d but the server expects %d", current, expected)
+	}
+	return check
+}
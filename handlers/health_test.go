@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leeshuoan/gds-OneCV/db"
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d; got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	latest, err := db.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		pingErr        error
+		version        int
+		expectedStatus int
+		failedCheck    string
+	}{
+		{"Ready", nil, latest, http.StatusOK, ""},
+		{"Database Unreachable", errors.New("connection refused"), latest, http.StatusServiceUnavailable, "database"},
+		{"Pending Migrations", nil, latest - 1, http.StatusServiceUnavailable, "migrations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			mock.ExpectPing().WillReturnError(tt.pingErr)
+			mock.ExpectQuery(`SELECT to_regclass\('schema_migrations'\) IS NOT NULL`).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(tt.version))
+
+			rr := httptest.NewRecorder()
+			Readyz(rr, httptest.NewRequest("GET", "/readyz", nil), conn, time.Second)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d; got %d", tt.expectedStatus, rr.Code)
+			}
+
+			var response models.HealthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			for name, check := range response.Checks {
+				expected := "ok"
+				if name == tt.failedCheck {
+					expected = "failed"
+				}
+				if check.Status != expected {
+					t.Errorf("Expected %s check to be %s; got %s (%s)", name, expected, check.Status, check.Error)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
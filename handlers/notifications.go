@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// ListNotifications lists sent notifications, newest first, optionally only
+// those sent by a teacher, received by a student or sent between from and
+// to. A teacher's key only lists that teacher's notifications.
+func ListNotifications(w http.ResponseWriter, r *http.Request, s store.Store) {
+	limit, offset, errs := parsePagination(r)
+	var filter store.NotificationFilter
+	var paramErrs []models.FieldError
+	filter.Teacher, paramErrs = parseEmailParam(r, "teacher")
+	errs = append(errs, paramErrs...)
+	filter.Student, paramErrs = parseEmailParam(r, "student")
+	errs = append(errs, paramErrs...)
+	filter.From, paramErrs = parseTimeParam(r, "from")
+	errs = append(errs, paramErrs...)
+	filter.To, paramErrs = parseTimeParam(r, "to")
+	errs = append(errs, paramErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	if filter.Teacher == "" {
+		filter.Teacher = identity.Teacher
+	}
+	if !identity.CanView(filter.Teacher) {
+		utils.SendJSONError(w, http.StatusForbidden, fmt.Sprintf("The API key cannot view the notifications of teacher %s", filter.Teacher))
+		return
+	}
+
+	notifications, total, err := s.ListNotifications(r.Context(), filter, limit, offset)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	response := models.NotificationListResponse{
+		Notifications: make([]models.Notification, len(notifications)),
+		Total:         total,
+		Limit:         limit,
+		Offset:        offset,
+	}
+	for i, notification := range notifications {
+		response.Notifications[i] = notificationResponse(notification)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetNotification(w http.ResponseWriter, r *http.Request, s store.Store) {
+	id, errs := parseIDParam(r, store.EntityNotification)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	notification, err := s.GetNotification(r.Context(), id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+	identity, _ := auth.FromContext(r.Context())
+	if !identity.CanView(notification.Teacher) {
+		// Other teachers' notifications are reported as missing rather than
+		// confirming that they exist.
+		sendStoreError(w, &store.Error{Entity: store.EntityNotification, Email: mux.Vars(r)["id"], Err: store.ErrNotFound})
+		return
+	}
+
+	deliveries, err := s.ListDeliveries(r.Context(), id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+	response := notificationResponse(notification)
+	for _, d := range deliveries {
+		response.Deliveries = append(response.Deliveries, models.Delivery{
+			Recipient:   d.Recipient,
+			Channel:     d.Channel,
+			Status:      d.Status,
+			Attempts:    d.Attempts,
+			LastError:   d.LastError,
+			DeliveredAt: d.DeliveredAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func notificationResponse(notification store.Notification) models.Notification {
+	return models.Notification{
+		ID:             notification.ID,
+		Teacher:        notification.Teacher,
+		Notification:   notification.Message,
+		CreatedAt:      notification.CreatedAt,
+		RecipientCount: notification.RecipientCount,
+		Recipients:     notification.Recipients,
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+func TestNotifications(t *testing.T) {
+	s := newSeededStore(t)
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+
+	send := func(teacher, message string) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(
+			`{"teacher": "`+teacher+`", "notification": "`+message+`"}`))
+		req = asTeacher(req, teacher)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		RetrieveForNotifications(rr, req, s, []string{"email"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d; got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		now = now.Add(24 * time.Hour)
+	}
+	send("teacherken@gmail.com", "Exam notice @studentagnes@gmail.com")
+	send("teacherjoe@gmail.com", "Sports day")
+	send("teacherken@gmail.com", "Results are out")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/notifications", func(w http.ResponseWriter, r *http.Request) { ListNotifications(w, r, s) })
+	router.HandleFunc("/notifications/{id}", func(w http.ResponseWriter, r *http.Request) { GetNotification(w, r, s) })
+
+	list := func(t *testing.T, req *http.Request) models.NotificationListResponse {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d; got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response models.NotificationListResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("List By Student And Date", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications?student=studentagnes%40gmail.com&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z", nil)
+		response := list(t, asAdmin(req))
+
+		if response.Total != 1 || response.Notifications[0].Notification != "Exam notice @studentagnes@gmail.com" {
+			t.Errorf("Expected only the exam notice; got %+v", response)
+		}
+	})
+
+	t.Run("Teacher Key Lists Own Notifications", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications", nil)
+		response := list(t, asTeacher(req, "teacherken@gmail.com"))
+
+		if response.Total != 2 || response.Notifications[0].Notification != "Results are out" {
+			t.Errorf("Expected teacherken's 2 notifications, newest first; got %+v", response)
+		}
+	})
+
+	t.Run("Teacher Key Cannot List Another Teacher", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications?teacher=teacherjoe%40gmail.com", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, asTeacher(req, "teacherken@gmail.com"))
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d; got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Get Notification", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications/1", nil)
+		req = req.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Role: auth.RoleAuditor}))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		expectedResponse := `{"id":1,"teacher":"teacherken@gmail.com","notification":"Exam notice @studentagnes@gmail.com",` +
+			`"createdAt":"2024-03-01T09:00:00Z","recipientCount":4,` +
+			`"recipients":["commonstudent1@gmail.com","commonstudent2@gmail.com","student_only_under_teacher_ken@gmail.com","studentagnes@gmail.com"],` +
+			`"deliveries":[{"recipient":"commonstudent1@gmail.com","channel":"email","status":"pending","attempts":0},`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Get Another Teacher's Notification", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications/2", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, asTeacher(req, "teacherken@gmail.com"))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Unknown Notification", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/notifications/99", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, asAdmin(req))
+
+		expectedResponse := `"code":"notification_not_found"`
+		if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected status %d and %s; got %d %s", http.StatusNotFound, expectedResponse, rr.Code, rr.Body.String())
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+func parsePagination(r *http.Request) (limit, offset int, errs []models.FieldError) {
+	query := r.URL.Query()
+
+	var err error
+	limit = defaultPageLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			errs = append(errs, models.FieldError{Field: "limit", Message: "must be a number between 1 and 500"})
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			errs = append(errs, models.FieldError{Field: "offset", Message: "must be a non-negative number"})
+		}
+	}
+
+	return limit, offset, errs
+}
+
+// parseIDParam reads the numeric ID of an entity from the route.
+func parseIDParam(r *http.Request, entity string) (int64, []models.FieldError) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, []models.FieldError{{Field: "id", Message: "must be a " + entity + " ID"}}
+	}
+	return id, nil
+}
+
+// parseEmailPath canonicalizes the email that identifies an entity in the
+// route.
+func parseEmailPath(r *http.Request) (string, []models.FieldError) {
+	email, ok := models.NormalizeEmail(mux.Vars(r)["email"])
+	if !ok {
+		return "", []models.FieldError{{Field: "email", Message: "not a valid email"}}
+	}
+	return email, nil
+}
+
+// parseCursorPagination reads the limit and cursor query parameters of
+// endpoints paged by cursor. A limit of 0 means that no limit was given and
+// after is the key the page starts after.
+func parseCursorPagination(r *http.Request) (limit int, after string, errs []models.FieldError) {
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageLimit {
+			errs = append(errs, models.FieldError{Field: "limit", Message: "must be a number between 1 and 500"})
+		}
+		limit = n
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(decoded) == 0 {
+			errs = append(errs, models.FieldError{Field: "cursor", Message: "is not a cursor returned by this endpoint"})
+		}
+		after = string(decoded)
+	}
+
+	return limit, after, errs
+}
+
+// parseCommonMode reads the mode query parameter of CommonStudents and returns
+// how many of teachers a student must be registered to: "all" (the default),
+// "any" or "atLeast=K".
+func parseCommonMode(r *http.Request, teachers []string) (minTeachers int, errs []models.FieldError) {
+	distinct := make(map[string]bool)
+	for _, teacher := range teachers {
+		distinct[teacher] = true
+	}
+
+	mode := r.URL.Query().Get("mode")
+	switch {
+	case mode == "" || mode == "all":
+		return len(distinct), nil
+	case mode == "any":
+		return 1, nil
+	case strings.HasPrefix(mode, "atLeast="):
+		k, err := strconv.Atoi(strings.TrimPrefix(mode, "atLeast="))
+		if err != nil || k < 1 || k > len(distinct) {
+			message := fmt.Sprintf("atLeast must be a number between 1 and the %d teachers given", len(distinct))
+			return 0, []models.FieldError{{Field: "mode", Message: message}}
+		}
+		return k, nil
+	}
+	return 0, []models.FieldError{{Field: "mode", Message: "must be 'all', 'any' or 'atLeast=K'"}}
+}
+
+// parseEmailParam canonicalizes an optional email query parameter.
+func parseEmailParam(r *http.Request, name string) (string, []models.FieldError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return "", nil
+	}
+	email, ok := models.NormalizeEmail(value)
+	if !ok {
+		return "", []models.FieldError{{Field: name, Message: "not a valid email"}}
+	}
+	return email, nil
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter.
+func parseTimeParam(r *http.Request, name string) (time.Time, []models.FieldError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, []models.FieldError{{Field: name, Message: "must be an RFC 3339 timestamp"}}
+	}
+	return at, nil
+}
+
+func parseBoolParam(r *http.Request, name string) (value bool, errs []models.FieldError) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, []models.FieldError{{Field: name, Message: "must be either true or false"}}
+	}
+	return value, nil
+}
+
+// encodeCursor turns the last key on a page into the cursor for the next.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// parseRegistrationsPolicy reads the registrations query parameter used when
+// deleting a teacher or student: "reject" (the default) refuses to delete
+// while registrations exist, "remove" deletes them as well.
+func parseRegistrationsPolicy(r *http.Request) (removeRegistrations bool, errs []models.FieldError) {
+	switch r.URL.Query().Get("registrations") {
+	case "", "reject":
+		return false, nil
+	case "remove":
+		return true, nil
+	}
+	return false, []models.FieldError{{Field: "registrations", Message: "must be either 'reject' or 'remove'"}}
+}
+
+// parseEmails canonicalizes the emails given for a query parameter, reporting
+// each invalid one as name[i].
+func parseEmails(name string, values []string) ([]string, []models.FieldError) {
+	var errs []models.FieldError
+	emails := make([]string, len(values))
+	for i, value := range values {
+		email, ok := models.NormalizeEmail(value)
+		if !ok {
+			errs = append(errs, models.FieldError{Field: fmt.Sprintf("%s[%d]", name, i), Message: "not a valid email"})
+		}
+		emails[i] = email
+	}
+	return emails, errs
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+func CreateStudent(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.StudentRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if err := s.CreateStudent(r.Context(), request.Email); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.StudentResponse{Email: request.Email})
+}
+
+func CreateStudents(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.BulkStudentsRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	created, existing, err := s.CreateStudents(r.Context(), request.Students)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.BulkStudentsResponse{Created: created, AlreadyExists: existing})
+}
+
+func ListStudents(w http.ResponseWriter, r *http.Request, s store.Store) {
+	limit, offset, errs := parsePagination(r)
+	teacher, paramErrs := parseEmailParam(r, "teacher")
+	errs = append(errs, paramErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := store.StudentFilter{
+		Teacher: teacher,
+		Domain:  query.Get("domain"),
+	}
+	if value := query.Get("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{{Field: "suspended", Message: "must be either true or false"}})
+			return
+		}
+		filter.Suspended = &suspended
+	}
+
+	students, total, err := s.ListStudents(r.Context(), filter, limit, offset)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	response := models.StudentListResponse{
+		Students: make([]models.StudentResponse, len(students)),
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	}
+	for i, student := range students {
+		response.Students[i] = studentResponse(student)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetStudent(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	student, err := s.GetStudent(r.Context(), email)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(studentResponse(student))
+}
+
+func UpdateStudent(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	var request models.StudentRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if err := s.UpdateStudent(r.Context(), email, request.Email); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	student, err := s.GetStudent(r.Context(), request.Email)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(studentResponse(student))
+}
+
+func DeleteStudent(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	removeRegistrations, paramErrs := parseRegistrationsPolicy(r)
+	errs = append(errs, paramErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	if err := s.DeleteStudent(r.Context(), email, removeRegistrations); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func studentResponse(student store.Student) models.StudentResponse {
+	return models.StudentResponse{
+		Email:          student.Email,
+		Suspended:      student.Suspended,
+		SuspendedUntil: student.SuspendedUntil,
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+func TestCreateStudents(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CreateStudents(w, r, s)
+	})
+
+	req := httptest.NewRequest("POST", "/students/bulk", strings.NewReader(`{"students": ["studentnew@gmail.com", "studentbob@gmail.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("Expected status %d; got %d", http.StatusCreated, status)
+	}
+
+	expectedResponse := `{"created":["studentnew@gmail.com"],"alreadyExists":["studentbob@gmail.com"]}`
+	if !strings.Contains(rr.Body.String(), expectedResponse) {
+		t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+	}
+}
+
+func TestCreateStudentsWithRepeatedEmails(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CreateStudents(w, r, s)
+	})
+
+	req := httptest.NewRequest("POST", "/students/bulk", strings.NewReader(`{"students": ["studentnew@gmail.com", "studentbob@gmail.com", "StudentNew@gmail.com", "studentbob@gmail.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("Expected status %d; got %d", http.StatusCreated, status)
+	}
+
+	expectedResponse := `{"created":["studentnew@gmail.com"],"alreadyExists":["studentbob@gmail.com"]}`
+	if !strings.Contains(rr.Body.String(), expectedResponse) {
+		t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+	}
+}
+
+func TestListStudents(t *testing.T) {
+	s := newSeededStore(t)
+	mustCreate(t, s, nil, []string{"studentann@school.edu.sg"})
+	if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "commonstudent2@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ListStudents(w, r, s)
+	})
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedResponse string
+	}{
+		{
+			"Registered To Teacher",
+			"?teacher=teacherjoe%40gmail.com",
+			`{"students":[{"email":"commonstudent1@gmail.com","suspended":false},{"email":"commonstudent2@gmail.com","suspended":true}],"total":2,"limit":50,"offset":0}`,
+		},
+		{
+			"Mixed-Case Teacher",
+			"?teacher=TeacherJoe%40Gmail.com",
+			`{"students":[{"email":"commonstudent1@gmail.com","suspended":false},{"email":"commonstudent2@gmail.com","suspended":true}],"total":2,"limit":50,"offset":0}`,
+		},
+		{
+			"Suspended",
+			"?suspended=true",
+			`{"students":[{"email":"commonstudent2@gmail.com","suspended":true}],"total":1,"limit":50,"offset":0}`,
+		},
+		{
+			"Email Domain",
+			"?domain=SCHOOL.edu.sg",
+			`{"students":[{"email":"studentann@school.edu.sg","suspended":false}],"total":1,"limit":50,"offset":0}`,
+		},
+		{
+			"Combined Filters",
+			"?teacher=teacherken%40gmail.com&suspended=false&limit=1&offset=1",
+			`{"students":[{"email":"student_only_under_teacher_ken@gmail.com","suspended":false}],"total":2,"limit":1,"offset":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/students"+tt.query, nil)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusOK {
+				t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+			}
+
+			if !strings.Contains(rr.Body.String(), tt.expectedResponse) {
+				t.Errorf("Expected response body %s; got %s", tt.expectedResponse, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Invalid Suspended Filter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/students?suspended=maybe", nil)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("Invalid Teacher Filter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/students?teacher=teacherjoe", nil)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `"detail":"teacher: not a valid email"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestGetStudent(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetStudent(w, r, s)
+	})
+
+	t.Run("Existing Student", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/students/studentbob@gmail.com", nil), map[string]string{"email": "studentbob@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"email":"studentbob@gmail.com","suspended":false}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Mixed-Case Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/students/StudentBob@Gmail.com", nil), map[string]string{"email": "StudentBob@Gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"email":"studentbob@gmail.com","suspended":false}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/students/studentbob", nil), map[string]string{"email": "studentbob"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `"detail":"email: not a valid email"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestUpdateStudent(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UpdateStudent(w, r, s)
+	})
+
+	req := mux.SetURLVars(
+		httptest.NewRequest("PUT", "/students/commonstudent1@gmail.com", strings.NewReader(`{"email": "commonstudent9@gmail.com"}`)),
+		map[string]string{"email": "commonstudent1@gmail.com"})
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+	}
+
+	common, _, _ := s.CommonStudents(context.Background(), store.CommonStudentsQuery{Teachers: []string{"teacherken@gmail.com", "teacherjoe@gmail.com"}})
+	if len(common) != 2 || common[0].Email != "commonstudent2@gmail.com" || common[1].Email != "commonstudent9@gmail.com" {
+		t.Errorf("Expected registrations to follow the new email; got %v", common)
+	}
+}
+
+func TestUpdateStudentWithMixedCaseEmail(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UpdateStudent(w, r, s)
+	})
+
+	req := mux.SetURLVars(
+		httptest.NewRequest("PUT", "/students/StudentBob@Gmail.com", strings.NewReader(`{"email": "studentrobert@gmail.com"}`)),
+		map[string]string{"email": "StudentBob@Gmail.com"})
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+	}
+
+	if _, err := s.GetStudent(req.Context(), "studentbob@gmail.com"); err == nil {
+		t.Errorf("Expected studentbob@gmail.com to be gone")
+	}
+}
+
+func TestDeleteStudent(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DeleteStudent(w, r, s)
+	})
+
+	t.Run("Registered Student Is Rejected", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/students/commonstudent1@gmail.com", nil), map[string]string{"email": "commonstudent1@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Expected status %d; got %d", http.StatusConflict, status)
+		}
+	})
+
+	t.Run("Unregistered Student Is Deleted", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/students/studentbob@gmail.com", nil), map[string]string{"email": "studentbob@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		if _, err := s.GetStudent(req.Context(), "studentbob@gmail.com"); err == nil {
+			t.Errorf("Expected studentbob@gmail.com to be deleted")
+		}
+	})
+
+	t.Run("Unknown Student", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/students/nobody@gmail.com", nil), map[string]string{"email": "nobody@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+	})
+
+	t.Run("Mixed-Case Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/students/StudentMiche@Gmail.com", nil), map[string]string{"email": "StudentMiche@Gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		if _, err := s.GetStudent(req.Context(), "studentmiche@gmail.com"); err == nil {
+			t.Errorf("Expected studentmiche@gmail.com to be deleted")
+		}
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/students/studentmiche", nil), map[string]string{"email": "studentmiche"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+func CreateTeacher(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.TeacherRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if err := s.CreateTeacher(r.Context(), request.Email); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.TeacherResponse{Email: request.Email, Students: []string{}})
+}
+
+func ListTeachers(w http.ResponseWriter, r *http.Request, s store.Store) {
+	limit, offset, errs := parsePagination(r)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	teachers, total, err := s.ListTeachers(r.Context(), limit, offset)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TeacherListResponse{
+		Teachers: teachers,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+
+func GetTeacher(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	teacher, err := s.GetTeacher(r.Context(), email)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TeacherResponse{Email: teacher.Email, Students: teacher.Students})
+}
+
+func UpdateTeacher(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	var request models.TeacherRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if err := s.UpdateTeacher(r.Context(), email, request.Email); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	teacher, err := s.GetTeacher(r.Context(), request.Email)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TeacherResponse{Email: teacher.Email, Students: teacher.Students})
+}
+
+func DeleteTeacher(w http.ResponseWriter, r *http.Request, s store.Store) {
+	email, errs := parseEmailPath(r)
+	removeRegistrations, paramErrs := parseRegistrationsPolicy(r)
+	errs = append(errs, paramErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	if err := s.DeleteTeacher(r.Context(), email, removeRegistrations); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
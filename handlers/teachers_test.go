@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCreateTeacher(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CreateTeacher(w, r, s)
+	})
+
+	t.Run("Successful Creation", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/teachers", strings.NewReader(`{"email": "teachermay@gmail.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Expected status %d; got %d", http.StatusCreated, status)
+		}
+
+		if exists, _ := s.TeacherExists(req.Context(), "teachermay@gmail.com"); !exists {
+			t.Errorf("Expected teachermay@gmail.com to exist")
+		}
+	})
+
+	t.Run("Duplicate Teacher", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/teachers", strings.NewReader(`{"email": "teacherken@gmail.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Expected status %d; got %d", http.StatusConflict, status)
+		}
+
+		expectedErrorMessage := `"detail":"Teacher teacherken@gmail.com already exists in the database"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestListTeachers(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ListTeachers(w, r, s)
+	})
+
+	t.Run("Paginated List", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/teachers?limit=1&offset=1", nil)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"teachers":["teacherken@gmail.com"],"total":2,"limit":1,"offset":1}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/teachers?limit=0", nil)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+}
+
+func TestGetTeacher(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetTeacher(w, r, s)
+	})
+
+	t.Run("Existing Teacher", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/teachers/teacherjoe@gmail.com", nil), map[string]string{"email": "teacherjoe@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"email":"teacherjoe@gmail.com","students":["commonstudent1@gmail.com","commonstudent2@gmail.com"]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Unknown Teacher", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/teachers/nobody@gmail.com", nil), map[string]string{"email": "nobody@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+	})
+
+	t.Run("Mixed-Case Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/teachers/TeacherJoe@Gmail.com", nil), map[string]string{"email": "TeacherJoe@Gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"email":"teacherjoe@gmail.com"`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/teachers/teacherjoe", nil), map[string]string{"email": "teacherjoe"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `"detail":"email: not a valid email"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestUpdateTeacher(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UpdateTeacher(w, r, s)
+	})
+
+	req := mux.SetURLVars(
+		httptest.NewRequest("PUT", "/teachers/teacherjoe@gmail.com", strings.NewReader(`{"email": "teacherjoseph@gmail.com"}`)),
+		map[string]string{"email": "teacherjoe@gmail.com"})
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+	}
+
+	expectedResponse := `{"email":"teacherjoseph@gmail.com","students":["commonstudent1@gmail.com","commonstudent2@gmail.com"]}`
+	if !strings.Contains(rr.Body.String(), expectedResponse) {
+		t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+	}
+
+	if exists, _ := s.TeacherExists(req.Context(), "teacherjoe@gmail.com"); exists {
+		t.Errorf("Expected teacherjoe@gmail.com to be gone")
+	}
+}
+
+func TestUpdateTeacherWithMixedCaseEmail(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UpdateTeacher(w, r, s)
+	})
+
+	req := mux.SetURLVars(
+		httptest.NewRequest("PUT", "/teachers/TeacherJoe@Gmail.com", strings.NewReader(`{"email": "teacherjoseph@gmail.com"}`)),
+		map[string]string{"email": "TeacherJoe@Gmail.com"})
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+	}
+
+	if exists, _ := s.TeacherExists(req.Context(), "teacherjoe@gmail.com"); exists {
+		t.Errorf("Expected teacherjoe@gmail.com to be gone")
+	}
+}
+
+func TestDeleteTeacher(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DeleteTeacher(w, r, s)
+	})
+
+	t.Run("Teacher With Registrations Is Rejected", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/teachers/teacherjoe@gmail.com", nil), map[string]string{"email": "teacherjoe@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Expected status %d; got %d", http.StatusConflict, status)
+		}
+
+		if exists, _ := s.TeacherExists(req.Context(), "teacherjoe@gmail.com"); !exists {
+			t.Errorf("Expected teacherjoe@gmail.com to remain")
+		}
+	})
+
+	t.Run("Teacher With Registrations Is Removed", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/teachers/teacherjoe@gmail.com?registrations=remove", nil), map[string]string{"email": "teacherjoe@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		if exists, _ := s.TeacherExists(req.Context(), "teacherjoe@gmail.com"); exists {
+			t.Errorf("Expected teacherjoe@gmail.com to be deleted")
+		}
+	})
+
+	t.Run("Invalid Policy", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/teachers/teacherken@gmail.com?registrations=keep", nil), map[string]string{"email": "teacherken@gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("Mixed-Case Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/teachers/TeacherKen@Gmail.com?registrations=remove", nil), map[string]string{"email": "TeacherKen@Gmail.com"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		if exists, _ := s.TeacherExists(req.Context(), "teacherken@gmail.com"); exists {
+			t.Errorf("Expected teacherken@gmail.com to be deleted")
+		}
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/teachers/teacherken", nil), map[string]string{"email": "teacherken"})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+}
@@ -1,184 +1,299 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
-	"github.com/leeshuoan/gds-OneCV/utils"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/middleware"
 	"github.com/leeshuoan/gds-OneCV/models"
-	"github.com/lib/pq"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
 )
 
-func Register(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func Register(w http.ResponseWriter, r *http.Request, s store.Store) {
 	var request models.RegistrationRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	middleware.SetTeacher(r.Context(), request.Teacher)
+	if !authorizeTeacher(w, r, request.Teacher) {
 		return
 	}
 
-	if request.Teacher == "" || len(request.Students) == 0 {
-		utils.SendJSONError(w, http.StatusBadRequest, "Both 'teacher' and 'students' fields are required in the request body")
+	result, err := s.RegisterStudents(r.Context(), request.Teacher, request.Students, request.Partial)
+	if err != nil {
+		sendStoreError(w, err)
 		return
 	}
 
-	for _, studentEmail := range request.Students {
-		sqlStatement := `INSERT INTO registrations (teacher_email, student_email) VALUES ($1, $2)`
-		_, err := db.Exec(sqlStatement, request.Teacher, studentEmail)
+	if !request.Partial {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegistrationResponse{
+		Registered:        result.Registered,
+		AlreadyRegistered: result.AlreadyRegistered,
+		UnknownStudents:   result.UnknownStudents,
+	})
+}
+
+// Deregister removes students from a teacher. With "all" set every student is
+// removed, for a teacher leaving the school.
+func Deregister(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.DeregistrationRequest
 
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	middleware.SetTeacher(r.Context(), request.Teacher)
+	if !authorizeTeacher(w, r, request.Teacher) {
+		return
+	}
+
+	var response models.DeregistrationResponse
+	if request.All {
+		students, err := s.DeregisterAll(r.Context(), request.Teacher)
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-				errorMessage := fmt.Sprintf("%s is already registered with this teacher", studentEmail)
-				utils.SendJSONError(w, http.StatusBadRequest, errorMessage)
-			} else if pqErr != nil {
-				constraintName := pqErr.Constraint
-				if constraintName == "registrations_teacher_email_fkey" {
-					errorMessage := fmt.Sprintf("Teacher %s does not exist in the database", request.Teacher)
-					utils.SendJSONError(w, http.StatusBadRequest, errorMessage)
-				} else if constraintName == "registrations_student_email_fkey" {
-					errorMessage := fmt.Sprintf("Student %s does not exist in the database", studentEmail)
-					utils.SendJSONError(w, http.StatusBadRequest, errorMessage)
-				} else {
-					utils.SendJSONError(w, http.StatusBadRequest, err.Error())
-				}
-			} else {
-				utils.SendJSONError(w, http.StatusBadRequest, err.Error())
-			}
+			sendStoreError(w, err)
 			return
 		}
+		response = models.DeregistrationResponse{Deregistered: students, NotRegistered: []string{}}
+	} else {
+		result, err := s.DeregisterStudents(r.Context(), request.Teacher, request.Students)
+		if err != nil {
+			sendStoreError(w, err)
+			return
+		}
+		response = models.DeregistrationResponse{Deregistered: result.Deregistered, NotRegistered: result.NotRegistered}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-func CommonStudents(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+// CommonStudents finds the students registered to the given teachers: to all
+// of them by default, or as chosen by mode, to any of them or to at least K.
+func CommonStudents(w http.ResponseWriter, r *http.Request, s store.Store) {
 	teacherEmails, ok := r.URL.Query()["teacher"]
 	if !ok || len(teacherEmails) < 1 {
-		utils.SendJSONError(w, http.StatusBadRequest, "At least one teacher is required in the query parameter")
+		utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{{Field: "teacher", Message: "at least one teacher is required"}})
 		return
 	}
-
-	placeholders := make([]string, len(teacherEmails))
-	args := make([]interface{}, len(teacherEmails))
-	for i, email := range teacherEmails {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-		args[i] = email
+	teacherEmails, errs := parseEmails("teacher", teacherEmails)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
 	}
-
-	query := fmt.Sprintf(`
-			SELECT student_email
-			FROM registrations
-			WHERE teacher_email IN (%s)
-			GROUP BY student_email
-			HAVING COUNT(DISTINCT teacher_email) = $%d
-	`, strings.Join(placeholders, ","), len(teacherEmails)+1)
-
-	sqlStatement, err := db.Prepare(query)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	limit, after, errs := parseCursorPagination(r)
+	minTeachers, modeErrs := parseCommonMode(r, teacherEmails)
+	errs = append(errs, modeErrs...)
+	countTotal, totalErrs := parseBoolParam(r, "total")
+	errs = append(errs, totalErrs...)
+	withDetails, detailsErrs := parseBoolParam(r, "details")
+	errs = append(errs, detailsErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
 		return
 	}
+	middleware.SetTeacher(r.Context(), strings.Join(teacherEmails, ","))
 
-	args = append(args, len(teacherEmails))
-
-	rows, err := sqlStatement.Query(args...)
+	query := store.CommonStudentsQuery{
+		Teachers:    teacherEmails,
+		MinTeachers: minTeachers,
+		After:       after,
+		CountTotal:  countTotal,
+	}
+	if limit > 0 {
+		// One extra student tells whether there is a next page.
+		query.Limit = limit + 1
+	}
+	students, total, err := s.CommonStudents(r.Context(), query)
 	if err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		sendStoreError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	var students []string
-	for rows.Next() {
-		var studentEmail string
-		if err := rows.Scan(&studentEmail); err != nil {
-			utils.SendJSONError(w, http.StatusBadRequest, err.Error())
-			return
+	var response models.CommonStudentsResponse
+	if limit > 0 && len(students) > limit {
+		students = students[:limit]
+		next := r.URL.Query()
+		next.Set("cursor", encodeCursor(students[limit-1].Email))
+		response.Next = r.URL.Path + "?" + next.Encode()
+	}
+	for _, student := range students {
+		response.Students = append(response.Students, student.Email)
+		if withDetails {
+			response.Details = append(response.Details, models.CommonStudent{
+				Email:     student.Email,
+				Teachers:  student.Teachers,
+				Suspended: student.Suspended,
+			})
 		}
-		students = append(students, studentEmail)
+	}
+	if countTotal {
+		response.Total = &total
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(models.CommonStudentsResponse{Students: students})
+	json.NewEncoder(w).Encode(response)
 }
 
-func Suspend(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func Suspend(w http.ResponseWriter, r *http.Request, s store.Store) {
 	var request models.SuspendRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	if !decodeRequest(w, r, &request) {
 		return
 	}
 
-	if request.Student == "" {
-		utils.SendJSONError(w, http.StatusBadRequest, "'student' is required in the request body")
+	until, err := suspensionEnd(request, time.Now())
+	if err != nil {
+		utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{{Field: "until", Message: err.Error()}})
 		return
 	}
 
-	sqlStatement := `UPDATE students SET is_suspended = true WHERE student_email = $1`
-	_, err := db.Exec(sqlStatement, request.Student)
-
+	identity, _ := auth.FromContext(r.Context())
+	params := store.SuspendParams{
+		Student:     request.Student,
+		SuspendedBy: identity.Subject(),
+		Reason:      request.Reason,
+		Until:       until,
+	}
+	alreadySuspended, err := s.SuspendStudent(r.Context(), params)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
-			errorMessage := fmt.Sprintf("Student %s does not exist in the database", request.Student)
-			utils.SendJSONError(w, http.StatusBadRequest, errorMessage)
-		} else {
-			utils.SendJSONError(w, http.StatusBadRequest, err.Error())
-		}
+		sendStoreError(w, err)
+		return
+	}
+
+	if alreadySuspended {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.SuspendResponse{AlreadySuspended: true})
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func RetrieveForNotifications(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var request models.NotificationRequest
+// suspensionEnd works out when a requested suspension should lapse, or nil
+// if it lasts until the student is unsuspended. The request must already have
+// passed Validate, so only the checks that depend on now are left.
+func suspensionEnd(request models.SuspendRequest, now time.Time) (*time.Time, error) {
+	if request.Until != "" {
+		until, err := time.Parse(time.RFC3339, request.Until)
+		if err != nil {
+			return nil, err
+		}
+		if !until.After(now) {
+			return nil, errors.New("must be in the future")
+		}
+		return &until, nil
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	if request.DurationDays > 0 {
+		until := now.AddDate(0, 0, request.DurationDays)
+		return &until, nil
+	}
+	return nil, nil
+}
+
+func Unsuspend(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.UnsuspendRequest
+
+	if !decodeRequest(w, r, &request) {
 		return
 	}
 
-	if request.Teacher == "" || request.Notification == "" {
-		utils.SendJSONError(w, http.StatusBadRequest, "Both 'teacher' and 'notification' fields are required in the request body")
+	wasSuspended, err := s.UnsuspendStudent(r.Context(), request.Student)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+	if !wasSuspended {
+		utils.SendProblem(w, utils.Problem{
+			Status: http.StatusConflict,
+			Code:   "student_not_suspended",
+			Detail: fmt.Sprintf("Student %s is not suspended", request.Student),
+			Email:  request.Student,
+		})
 		return
 	}
 
-	teacherEmail := request.Teacher
-	notification := request.Notification
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	mentionedStudents := utils.ParseMentionedStudents(notification)
+func SuspensionHistory(w http.ResponseWriter, r *http.Request, s store.Store) {
+	studentEmail, errs := parseEmailParam(r, "student")
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if studentEmail == "" {
+		utils.SendFieldErrors(w, http.StatusBadRequest, []models.FieldError{{Field: "student", Message: "is required in the query parameter"}})
+		return
+	}
 
-	query := `
-		SELECT DISTINCT r.student_email
-		FROM registrations r, students s
-		WHERE r.student_email = s.student_email AND teacher_email = $1 AND is_suspended = false
-		UNION
-		SELECT DISTINCT student_email
-		FROM students
-		WHERE student_email = ANY($2) AND is_suspended = false
-	`
-	rows, err := db.Query(query, teacherEmail, pq.Array(mentionedStudents))
+	student, err := s.GetStudent(r.Context(), studentEmail)
 	if err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		sendStoreError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	var students []string
-	for rows.Next() {
-		var studentEmail string
-		if err := rows.Scan(&studentEmail); err != nil {
-			utils.SendJSONError(w, http.StatusBadRequest, err.Error())
-			return
+	history, err := s.SuspensionHistory(r.Context(), studentEmail)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	response := models.SuspensionHistoryResponse{
+		Student:        student.Email,
+		Suspended:      student.Suspended,
+		SuspendedUntil: student.SuspendedUntil,
+		Suspensions:    make([]models.Suspension, len(history)),
+	}
+	for i, suspension := range history {
+		response.Suspensions[i] = models.Suspension{
+			SuspendedBy: suspension.SuspendedBy,
+			Reason:      suspension.Reason,
+			StartedAt:   suspension.StartedAt,
+			EndsAt:      suspension.EndsAt,
+			EndedAt:     suspension.EndedAt,
+			EndReason:   suspension.EndReason,
 		}
-		students = append(students, studentEmail)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RetrieveForNotifications records the notification and queues it for
+// delivery to every recipient on each of channels.
+func RetrieveForNotifications(w http.ResponseWriter, r *http.Request, s store.Store, channels []string) {
+	var request models.NotificationRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	middleware.SetTeacher(r.Context(), request.Teacher)
+	if !authorizeTeacher(w, r, request.Teacher) {
+		return
+	}
+
+	mentionedStudents := utils.ParseMentionedStudents(request.Notification)
 
+	notification, err := s.CreateNotification(r.Context(), request.Teacher, request.Notification, mentionedStudents, channels)
+	if err != nil {
+		sendStoreError(w, err)
+		return
 	}
-	response := models.NotificationResponse{Recipients: students}
+
+	response := models.NotificationResponse{Recipients: notification.Recipients, NotificationID: notification.ID}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
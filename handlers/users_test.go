@@ -1,29 +1,95 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/leeshuoan/gds-OneCV/mocks"
-	"github.com/lib/pq"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
 )
 
+func mustCreate(t *testing.T, s *store.Memory, teachers, students []string) {
+	t.Helper()
+	for _, teacher := range teachers {
+		if err := s.CreateTeacher(context.Background(), teacher); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, student := range students {
+		if err := s.CreateStudent(context.Background(), student); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func asTeacher(req *http.Request, teacher string) *http.Request {
+	identity := auth.Identity{Role: auth.RoleTeacher, Teacher: teacher}
+	return req.WithContext(auth.WithIdentity(req.Context(), identity))
+}
+
+func asAdmin(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Role: auth.RoleAdmin}))
+}
+
+// newSeededStore returns a store holding the sample rows from db/seed.sql.
+func newSeededStore(t *testing.T) *store.Memory {
+	t.Helper()
+	s := store.NewMemory()
+	mustCreate(t, s,
+		[]string{"teacherken@gmail.com", "teacherjoe@gmail.com"},
+		[]string{
+			"studentjon@gmail.com",
+			"studenthon@gmail.com",
+			"commonstudent1@gmail.com",
+			"commonstudent2@gmail.com",
+			"student_only_under_teacher_ken@gmail.com",
+			"studentmary@gmail.com",
+			"studentbob@gmail.com",
+			"studentagnes@gmail.com",
+			"studentmiche@gmail.com",
+		})
+
+	registrations := map[string][]string{
+		"teacherken@gmail.com": {"commonstudent1@gmail.com", "commonstudent2@gmail.com", "student_only_under_teacher_ken@gmail.com"},
+		"teacherjoe@gmail.com": {"commonstudent1@gmail.com", "commonstudent2@gmail.com"},
+	}
+	for teacher, students := range registrations {
+		if _, err := s.RegisterStudents(context.Background(), teacher, students, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// notificationRecipients returns the students a notification from teacher
+// reaches.
+func notificationRecipients(t *testing.T, s store.Store, teacher string) []string {
+	t.Helper()
+	notification, err := s.CreateNotification(context.Background(), teacher, "Hello", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notification.Recipients
+}
+
 func TestRegister(t *testing.T) {
-	db, mock := mocks.NewMock()
-	defer db.Close()
+	s := store.NewMemory()
+	mustCreate(t, s, []string{"teacher@example.com"}, []string{"studentjon@example.com", "studenthon@example.com", "student@example.com"})
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		Register(w, r, db)
+		Register(w, r, s)
 	})
 
 	t.Run("Successful Registration", func(t *testing.T) {
-		mock.ExpectExec(`INSERT INTO registrations`).WithArgs("teacher@example.com", "studentjon@example.com").WillReturnResult(sqlmock.NewResult(1, 1))
-		mock.ExpectExec(`INSERT INTO registrations`).WithArgs("teacher@example.com", "studenthon@example.com").WillReturnResult(sqlmock.NewResult(1, 1))
-
 		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["studentjon@example.com", "studenthon@example.com"]}`))
+		req = asTeacher(req, "teacher@example.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -36,6 +102,7 @@ func TestRegister(t *testing.T) {
 
 	t.Run("Missing Teacher Field", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"students": ["student@example.com"]}`))
+		req = asAdmin(req)
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -45,7 +112,7 @@ func TestRegister(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := "Both 'teacher' and 'students' fields are required in the request body"
+		expectedErrorMessage := `{"field":"teacher","message":"is required"}`
 		responseBody := rr.Body.String()
 		if !strings.Contains(responseBody, expectedErrorMessage) {
 			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, responseBody)
@@ -54,6 +121,7 @@ func TestRegister(t *testing.T) {
 
 	t.Run("Missing Students Field", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com"}`))
+		req = asTeacher(req, "teacher@example.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -63,7 +131,7 @@ func TestRegister(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := "Both 'teacher' and 'students' fields are required in the request body"
+		expectedErrorMessage := `{"field":"students","message":"must list at least one email"}`
 		responseBody := rr.Body.String()
 		if !strings.Contains(responseBody, expectedErrorMessage) {
 			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, responseBody)
@@ -71,19 +139,23 @@ func TestRegister(t *testing.T) {
 	})
 
 	t.Run("Duplicate Student Registration", func(t *testing.T) {
-		mock.ExpectExec(`INSERT INTO registrations`).WithArgs("teacher@example.com", "student@example.com").WillReturnError(&pq.Error{Code: "23505"})
-
 		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["student@example.com"]}`))
+		req = asTeacher(req, "teacher@example.com")
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["student@example.com"]}`))
+		req = asTeacher(req, "teacher@example.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler.ServeHTTP(rr, req)
 
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Expected status %d; got %d", http.StatusConflict, status)
 		}
 
-		expectedErrorMessage := "student@example.com is already registered with this teacher"
+		expectedErrorMessage := `"detail":"student@example.com is already registered with this teacher","code":"registration_already_exists","email":"student@example.com"`
 		responseBody := rr.Body.String()
 		if !strings.Contains(responseBody, expectedErrorMessage) {
 			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, responseBody)
@@ -91,19 +163,18 @@ func TestRegister(t *testing.T) {
 	})
 
 	t.Run("Non-Existent Teacher", func(t *testing.T) {
-		mock.ExpectExec(`INSERT INTO registrations`).WithArgs("teacher@example.com", "student@example.com").WillReturnError(&pq.Error{Constraint: "registrations_teacher_email_fkey"})
-
-		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["student@example.com"]}`))
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "unknown@example.com", "students": ["student@example.com"]}`))
+		req = asTeacher(req, "unknown@example.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler.ServeHTTP(rr, req)
 
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
 		}
 
-		expectedErrorMessage := "Teacher teacher@example.com does not exist in the database"
+		expectedErrorMessage := `"code":"teacher_not_found","email":"unknown@example.com"`
 		responseBody := rr.Body.String()
 		if !strings.Contains(responseBody, expectedErrorMessage) {
 			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, responseBody)
@@ -111,41 +182,153 @@ func TestRegister(t *testing.T) {
 	})
 
 	t.Run("Non-Existent Student", func(t *testing.T) {
-		mock.ExpectExec(`INSERT INTO registrations`).WithArgs("teacher@example.com", "student@example.com").WillReturnError(&pq.Error{Constraint: "registrations_student_email_fkey"})
-
-		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["student@example.com"]}`))
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["unknown@example.com"]}`))
+		req = asTeacher(req, "teacher@example.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler.ServeHTTP(rr, req)
 
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
 		}
 
-		expectedErrorMessage := "Student student@example.com does not exist in the database"
+		expectedErrorMessage := `"code":"student_not_found","email":"unknown@example.com"`
 		responseBody := rr.Body.String()
 		if !strings.Contains(responseBody, expectedErrorMessage) {
 			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, responseBody)
 		}
 	})
+
+	t.Run("Failed Registration Registers Nobody", func(t *testing.T) {
+		mustCreate(t, s, nil, []string{"studentann@example.com"})
+
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["studentann@example.com", "unknown@example.com"]}`))
+		req = asTeacher(req, "teacher@example.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+
+		common, _, _ := s.CommonStudents(context.Background(), store.CommonStudentsQuery{Teachers: []string{"teacher@example.com"}})
+		for _, student := range common {
+			if student.Email == "studentann@example.com" {
+				t.Errorf("Expected studentann@example.com not to be registered")
+			}
+		}
+	})
+
+	t.Run("Partial Registration", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"teacher": "teacher@example.com", "students": ["studentann@example.com", "student@example.com", "unknown@example.com"], "partial": true}`))
+		req = asTeacher(req, "teacher@example.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"registered":["studentann@example.com"],"alreadyRegistered":["student@example.com"],"unknownStudents":["unknown@example.com"]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+}
+
+func TestDeregister(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Deregister(w, r, s)
+	})
+
+	t.Run("Successful Deregistration", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/deregister", strings.NewReader(`{"teacher": "teacherken@gmail.com", "students": ["commonstudent1@gmail.com", "studentbob@gmail.com"]}`))
+		req = asTeacher(req, "teacherken@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"deregistered":["commonstudent1@gmail.com"],"notRegistered":["studentbob@gmail.com"]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+
+		recipients := notificationRecipients(t, s, "teacherken@gmail.com")
+		if strings.Contains(strings.Join(recipients, ","), "commonstudent1@gmail.com") {
+			t.Errorf("Expected deregistered student to stop receiving notifications; got %v", recipients)
+		}
+	})
+
+	t.Run("Deregister All", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/deregister", strings.NewReader(`{"teacher": "teacherjoe@gmail.com", "all": true}`))
+		req = asTeacher(req, "teacherjoe@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"deregistered":["commonstudent1@gmail.com","commonstudent2@gmail.com"],"notRegistered":[]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Both Students And All", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/deregister", strings.NewReader(`{"teacher": "teacherken@gmail.com", "students": ["commonstudent2@gmail.com"], "all": true}`))
+		req = asTeacher(req, "teacherken@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("Non-Existent Teacher", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/deregister", strings.NewReader(`{"teacher": "unknown@gmail.com", "all": true}`))
+		req = asTeacher(req, "unknown@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+
+		expectedErrorMessage := "Teacher unknown@gmail.com does not exist in the database"
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected error message '%s' in response body; got '%s'", expectedErrorMessage, rr.Body.String())
+		}
+	})
 }
 
 func TestCommonStudents(t *testing.T) {
-	db, mock := mocks.NewMock()
-	defer db.Close()
+	s := newSeededStore(t)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		CommonStudents(w, r, db)
+		CommonStudents(w, r, s)
 	})
 
 	t.Run("Successful Common Students", func(t *testing.T) {
-		mock.ExpectPrepare("SELECT student_email").ExpectQuery().
-			WithArgs("teacherken@gmail.com", "teacherjoe@gmail.com", 2).
-			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
-				AddRow("commonstudent1@gmail.com").
-				AddRow("commonstudent2@gmail.com"))
-
 		req := httptest.NewRequest("GET", "/common-students?teacher=teacherken%40gmail.com&teacher=teacherjoe%40gmail.com", nil)
 
 		rr := httptest.NewRecorder()
@@ -162,13 +345,129 @@ func TestCommonStudents(t *testing.T) {
 		}
 	})
 
-	t.Run("No Teacher in Query", func(t *testing.T) {
-		mock.ExpectPrepare("SELECT student_email").ExpectQuery().
-			WithArgs("teacherken@gmail.com", "teacherjoe@gmail.com", 2).
-			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
-				AddRow("commonstudent1@gmail.com").
-				AddRow("commonstudent2@gmail.com"))
+	t.Run("Paged Common Students", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/common-students?teacher=teacherken%40gmail.com&teacher=teacherjoe%40gmail.com&limit=1&total=true", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var page models.CommonStudentsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(page.Students, []string{"commonstudent1@gmail.com"}) || page.Total == nil || *page.Total != 2 || page.Next == "" {
+			t.Fatalf("Expected the first of 2 students and a next link; got %+v", page)
+		}
+
+		req = httptest.NewRequest("GET", page.Next, nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
 
+		expectedResponse := `{"students":["commonstudent2@gmail.com"],"total":2}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/common-students?teacher=teacherken%40gmail.com&cursor=%21%21", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("Modes", func(t *testing.T) {
+		mustCreate(t, s, []string{"teacheramy@gmail.com"}, nil)
+		if _, err := s.RegisterStudents(context.Background(), "teacheramy@gmail.com", []string{"commonstudent1@gmail.com", "studentagnes@gmail.com"}, false); err != nil {
+			t.Fatal(err)
+		}
+		teachers := "teacher=teacherken%40gmail.com&teacher=teacherjoe%40gmail.com&teacher=teacheramy%40gmail.com"
+
+		tests := []struct {
+			mode             string
+			expectedStatus   int
+			expectedStudents []string
+		}{
+			{"all", http.StatusOK, []string{"commonstudent1@gmail.com"}},
+			{"any", http.StatusOK, []string{
+				"commonstudent1@gmail.com", "commonstudent2@gmail.com", "student_only_under_teacher_ken@gmail.com", "studentagnes@gmail.com",
+			}},
+			{"atLeast=2", http.StatusOK, []string{"commonstudent1@gmail.com", "commonstudent2@gmail.com"}},
+			{"atLeast=4", http.StatusBadRequest, nil},
+			{"most", http.StatusBadRequest, nil},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest("GET", "/common-students?"+teachers+"&mode="+tt.mode, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("mode=%s: Expected status %d; got %d", tt.mode, tt.expectedStatus, rr.Code)
+				continue
+			}
+			if tt.expectedStatus != http.StatusOK {
+				continue
+			}
+			var response models.CommonStudentsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(response.Students, tt.expectedStudents) {
+				t.Errorf("mode=%s: Expected %v; got %v", tt.mode, tt.expectedStudents, response.Students)
+			}
+		}
+	})
+
+	t.Run("Details", func(t *testing.T) {
+		if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "student_only_under_teacher_ken@gmail.com"}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/common-students?teacher=teacherken%40gmail.com&teacher=teacherjoe%40gmail.com&mode=any&details=true", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		expectedResponse := `"details":[` +
+			`{"email":"commonstudent1@gmail.com","teachers":["teacherjoe@gmail.com","teacherken@gmail.com"],"suspended":false},` +
+			`{"email":"commonstudent2@gmail.com","teachers":["teacherjoe@gmail.com","teacherken@gmail.com"],"suspended":false},` +
+			`{"email":"student_only_under_teacher_ken@gmail.com","teachers":["teacherken@gmail.com"],"suspended":true}]`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body to contain %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Teachers in Mixed Case", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/common-students?teacher=TeacherKen%40Gmail.com&teacher=%20teacherjoe%40gmail.com", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		expectedResponse := `{"students":["commonstudent1@gmail.com","commonstudent2@gmail.com"]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Teacher in Query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/common-students?teacher=teacherken%40gmail.com&teacher=teacherjoe", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `{"field":"teacher[1]","message":"not a valid email"}`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+
+	t.Run("No Teacher in Query", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/common-students", nil)
 
 		rr := httptest.NewRecorder()
@@ -179,7 +478,7 @@ func TestCommonStudents(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := `{"message":"At least one teacher is required in the query parameter"}`
+		expectedErrorMessage := `{"field":"teacher","message":"at least one teacher is required"}`
 		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
 			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
 		}
@@ -187,19 +486,15 @@ func TestCommonStudents(t *testing.T) {
 }
 
 func TestSuspend(t *testing.T) {
-	db, mock := mocks.NewMock()
-	defer db.Close()
+	s := newSeededStore(t)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		Suspend(w, r, db)
+		Suspend(w, r, s)
 	})
 
 	t.Run("Successful Suspension", func(t *testing.T) {
-		mock.ExpectExec("UPDATE students SET is_suspended = true").
-			WithArgs("studentmary@gmail.com").
-			WillReturnResult(sqlmock.NewResult(0, 1))
-
 		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentmary@gmail.com"}`))
+		req = asAdmin(req)
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -209,10 +504,15 @@ func TestSuspend(t *testing.T) {
 		if status := rr.Code; status != http.StatusNoContent {
 			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
 		}
+
+		if student, _ := s.GetStudent(req.Context(), "studentmary@gmail.com"); !student.Suspended {
+			t.Errorf("Expected studentmary@gmail.com to be suspended")
+		}
 	})
 
 	t.Run("Missing Student in Request Body", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{}`))
+		req = asAdmin(req)
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -223,18 +523,169 @@ func TestSuspend(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := `{"message":"'student' is required in the request body"}`
+		expectedErrorMessage := `"detail":"student: is required"`
 		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
 			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
 		}
 	})
 
 	t.Run("Student Not Found in Database", func(t *testing.T) {
-		mock.ExpectExec("UPDATE students SET is_suspended = true").
-			WithArgs("nonexistentstudent@gmail.com").
-			WillReturnError(&pq.Error{Code: "23503"})
-
 		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "nonexistentstudent@gmail.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+
+		expectedErrorMessage := `"detail":"Student nonexistentstudent@gmail.com does not exist in the database"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+
+	t.Run("Student Already Suspended", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentmary@gmail.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"alreadySuspended":true}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+
+		history, _ := s.SuspensionHistory(req.Context(), "studentmary@gmail.com")
+		if len(history) != 1 {
+			t.Errorf("Expected a single suspension to be recorded; got %d", len(history))
+		}
+	})
+}
+
+func TestSuspendRecordsCaller(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Suspend(w, r, s)
+	})
+
+	t.Run("Suspender Taken from the API Key", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentbob@gmail.com"}`))
+		identity := auth.Identity{KeyID: 7, Name: "office", Role: auth.RoleAdmin}
+		req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Fatalf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		history, _ := s.SuspensionHistory(req.Context(), "studentbob@gmail.com")
+		if len(history) != 1 {
+			t.Fatalf("Expected a single suspension to be recorded; got %d", len(history))
+		}
+		if expected := "api key 7 (office)"; history[0].SuspendedBy != expected {
+			t.Errorf("Expected the suspension to be recorded as by %q; got %q", expected, history[0].SuspendedBy)
+		}
+	})
+
+	t.Run("Suspender Taken from the Teacher", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentagnes@gmail.com"}`))
+		req = asTeacher(req, "teacherken@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Fatalf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		history, _ := s.SuspensionHistory(req.Context(), "studentagnes@gmail.com")
+		if len(history) != 1 || history[0].SuspendedBy != "teacherken@gmail.com" {
+			t.Errorf("Expected the suspension to be recorded as by teacherken@gmail.com; got %+v", history)
+		}
+	})
+
+	t.Run("Suspender Cannot Be Supplied", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentmiche@gmail.com", "suspendedBy": "someone@example.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+		if student, _ := s.GetStudent(req.Context(), "studentmiche@gmail.com"); student.Suspended {
+			t.Errorf("Expected studentmiche@gmail.com not to be suspended")
+		}
+	})
+}
+
+func TestTimeBoxedSuspension(t *testing.T) {
+	s := newSeededStore(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Suspend(w, r, s)
+	})
+
+	t.Run("Suspension Expires", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "commonstudent1@gmail.com", "durationDays": 2}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Fatalf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		recipients := notificationRecipients(t, s, "teacherjoe@gmail.com")
+		if strings.Contains(strings.Join(recipients, ","), "commonstudent1@gmail.com") {
+			t.Errorf("Expected suspended student to be excluded; got %v", recipients)
+		}
+
+		s.Now = func() time.Time { return time.Now().AddDate(0, 0, 3) }
+
+		recipients = notificationRecipients(t, s, "teacherjoe@gmail.com")
+		if !strings.Contains(strings.Join(recipients, ","), "commonstudent1@gmail.com") {
+			t.Errorf("Expected expired suspension to be ignored; got %v", recipients)
+		}
+
+		expired, _ := s.ExpireSuspensions(context.Background())
+		if len(expired) != 1 || expired[0] != "commonstudent1@gmail.com" {
+			t.Errorf("Expected commonstudent1@gmail.com to expire; got %v", expired)
+		}
+
+		history, _ := s.SuspensionHistory(context.Background(), "commonstudent1@gmail.com")
+		if len(history) != 1 || history[0].EndReason != store.EndReasonExpired {
+			t.Errorf("Expected one expired suspension; got %+v", history)
+		}
+	})
+
+	t.Run("Both Until and DurationDays", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentbob@gmail.com", "until": "2099-01-01T00:00:00Z", "durationDays": 2}`))
+		req = asAdmin(req)
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -245,7 +696,179 @@ func TestSuspend(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := `{"message":"Student nonexistentstudent@gmail.com does not exist in the database"}`
+		expectedErrorMessage := `{"field":"until","message":"only one of 'until' and 'durationDays' may be provided"}`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Until", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/suspend", strings.NewReader(`{"student": "studentbob@gmail.com", "until": "next week"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `{"field":"until","message":"must be an RFC 3339 timestamp"}`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestUnsuspend(t *testing.T) {
+	s := newSeededStore(t)
+	if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "studentmary@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Unsuspend(w, r, s)
+	})
+
+	t.Run("Successful Unsuspension", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/unsuspend", strings.NewReader(`{"student": "studentmary@gmail.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, status)
+		}
+
+		if student, _ := s.GetStudent(req.Context(), "studentmary@gmail.com"); student.Suspended {
+			t.Errorf("Expected studentmary@gmail.com not to be suspended")
+		}
+	})
+
+	t.Run("Student Not Suspended", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/unsuspend", strings.NewReader(`{"student": "studentbob@gmail.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Expected status %d; got %d", http.StatusConflict, status)
+		}
+
+		expectedErrorMessage := `"detail":"Student studentbob@gmail.com is not suspended","code":"student_not_suspended"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+
+	t.Run("Student Not Found in Database", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/unsuspend", strings.NewReader(`{"student": "nonexistentstudent@gmail.com"}`))
+		req = asAdmin(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Expected status %d; got %d", http.StatusNotFound, status)
+		}
+
+		expectedErrorMessage := `"detail":"Student nonexistentstudent@gmail.com does not exist in the database"`
+		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
+			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
+		}
+	})
+}
+
+func TestSuspensionHistory(t *testing.T) {
+	s := newSeededStore(t)
+	now := time.Date(2023, 7, 1, 8, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+
+	if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "studentmary@gmail.com", SuspendedBy: "teacherken@gmail.com", Reason: "Truancy"}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(24 * time.Hour)
+	if _, err := s.UnsuspendStudent(context.Background(), "studentmary@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "studentmary@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SuspensionHistory(w, r, s)
+	})
+
+	t.Run("Successful History Retrieval", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/suspensions?student=studentmary%40gmail.com", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"student":"studentmary@gmail.com","suspended":true,"suspensions":[` +
+			`{"suspendedBy":"teacherken@gmail.com","reason":"Truancy","startedAt":"2023-07-01T08:00:00Z","endedAt":"2023-07-02T08:00:00Z","endReason":"unsuspended"},` +
+			`{"startedAt":"2023-07-02T08:00:00Z"}]}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("No Student in Query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/suspensions", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("Mixed-Case Student in Query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/suspensions?student=StudentMary%40Gmail.com", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"student":"studentmary@gmail.com","suspended":true`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Student in Query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/suspensions?student=studentmary", nil)
+
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
+		}
+
+		expectedErrorMessage := `"detail":"student: not a valid email"`
 		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
 			t.Errorf("Expected response body %s; got %s", expectedErrorMessage, rr.Body.String())
 		}
@@ -253,23 +876,23 @@ func TestSuspend(t *testing.T) {
 }
 
 func TestRetrieveForNotifications(t *testing.T) {
-	db, mock := mocks.NewMock()
-	defer db.Close()
+	s := newSeededStore(t)
+	mustCreate(t, s, []string{"teacherbob@gmail.com"}, nil)
+	if _, err := s.RegisterStudents(context.Background(), "teacherbob@gmail.com", []string{"studentbob@gmail.com", "studentmary@gmail.com"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SuspendStudent(context.Background(), store.SuspendParams{Student: "studentmary@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		RetrieveForNotifications(w, r, db)
+		RetrieveForNotifications(w, r, s, nil)
 	})
 
 	t.Run("Successful Notification Retrieval with mentions", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT DISTINCT r.student_email`).
-			WithArgs("teacherken@gmail.com", pq.Array([]string{"studentagnes@gmail.com", "studentmiche@gmail.com"})).
-			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
-				AddRow("studentbob@gmail.com").
-				AddRow("studentagnes@gmail.com").
-				AddRow("studentmiche@gmail.com"))
-
-		reqBody := `{"teacher": "teacherken@gmail.com", "notification": "Hello students! @studentagnes@gmail.com @studentmiche@gmail.com"}`
+		reqBody := `{"teacher": "teacherbob@gmail.com", "notification": "Hello students! @studentagnes@gmail.com @studentmiche@gmail.com"}`
 		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(reqBody))
+		req = asTeacher(req, "teacherbob@gmail.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -280,20 +903,36 @@ func TestRetrieveForNotifications(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
 		}
 
-		expectedResponse := `{"recipients":["studentbob@gmail.com","studentagnes@gmail.com","studentmiche@gmail.com"]}`
+		expectedResponse := `{"recipients":["studentagnes@gmail.com","studentbob@gmail.com","studentmiche@gmail.com"],"notificationId":1}`
 		if !strings.Contains(rr.Body.String(), expectedResponse) {
 			t.Errorf("Expected response body to contain %s; got %s", expectedResponse, rr.Body.String())
 		}
 	})
 
-	t.Run("Successful Notification Retrieval without mentions", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT DISTINCT r.student_email`).
-			WithArgs("teacherken@gmail.com", pq.Array([]string{})).
-			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
-				AddRow("studentbob@gmail.com"))
+	t.Run("Mentions in Mixed Case", func(t *testing.T) {
+		reqBody := `{"teacher": "TeacherBob@gmail.com", "notification": "Hi @StudentAgnes@Gmail.com @studentagnes@gmail.com @everyone"}`
+		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(reqBody))
+		req = asTeacher(req, "teacherbob@gmail.com")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
 
-		reqBody := `{"teacher": "teacherken@gmail.com", "notification": "Hey everybody!"}`
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
+		}
+
+		expectedResponse := `{"recipients":["studentagnes@gmail.com","studentbob@gmail.com"],"notificationId":2}`
+		if !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected response body to contain %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Successful Notification Retrieval without mentions", func(t *testing.T) {
+		reqBody := `{"teacher": "teacherbob@gmail.com", "notification": "Hey everybody!"}`
 		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(reqBody))
+		req = asTeacher(req, "teacherbob@gmail.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -304,7 +943,7 @@ func TestRetrieveForNotifications(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusOK, status)
 		}
 
-		expectedResponse := `{"recipients":["studentbob@gmail.com"]}`
+		expectedResponse := `{"recipients":["studentbob@gmail.com"],"notificationId":3}`
 		if !strings.Contains(rr.Body.String(), expectedResponse) {
 			t.Errorf("Expected response body to contain %s; got %s", expectedResponse, rr.Body.String())
 		}
@@ -313,6 +952,7 @@ func TestRetrieveForNotifications(t *testing.T) {
 	t.Run("Missing Teacher in Request Body", func(t *testing.T) {
 		reqBody := `{"notification": "Hello @student@example.com"}`
 		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(reqBody))
+		req = asAdmin(req)
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -323,7 +963,7 @@ func TestRetrieveForNotifications(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := `{"message":"Both 'teacher' and 'notification' fields are required in the request body"}`
+		expectedErrorMessage := `{"field":"teacher","message":"is required"}`
 		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
 			t.Errorf("Expected response body to contain %s; got %s", expectedErrorMessage, rr.Body.String())
 		}
@@ -332,6 +972,7 @@ func TestRetrieveForNotifications(t *testing.T) {
 	t.Run("Missing Notification in Request Body", func(t *testing.T) {
 		reqBody := `{"teacher": "teacherken@gmail.com"}`
 		req := httptest.NewRequest("POST", "/notifications", strings.NewReader(reqBody))
+		req = asTeacher(req, "teacherken@gmail.com")
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
@@ -342,7 +983,7 @@ func TestRetrieveForNotifications(t *testing.T) {
 			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, status)
 		}
 
-		expectedErrorMessage := `{"message":"Both 'teacher' and 'notification' fields are required in the request body"}`
+		expectedErrorMessage := `{"field":"notification","message":"is required"}`
 		if !strings.Contains(rr.Body.String(), expectedErrorMessage) {
 			t.Errorf("Expected response body to contain %s; got %s", expectedErrorMessage, rr.Body.String())
 		}
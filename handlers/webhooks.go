@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request, s store.Store) {
+	var request models.WebhookRequest
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	webhook, err := s.CreateWebhook(r.Context(), store.Webhook{
+		URL:        request.URL,
+		Secret:     request.Secret,
+		EventTypes: request.EventTypes,
+	})
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhookResponse(webhook))
+}
+
+func ListWebhooks(w http.ResponseWriter, r *http.Request, s store.Store) {
+	webhooks, err := s.ListWebhooks(r.Context())
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	response := models.WebhookListResponse{Webhooks: make([]models.Webhook, len(webhooks))}
+	for i, webhook := range webhooks {
+		response.Webhooks[i] = webhookResponse(webhook)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request, s store.Store) {
+	id, errs := parseIDParam(r, store.EntityWebhook)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	webhook, err := s.GetWebhook(r.Context(), id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhookResponse(webhook))
+}
+
+// DeleteWebhook stops events from being sent to the webhook, including the
+// ones still waiting to be retried, and drops its delivery log.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request, s store.Store) {
+	id, errs := parseIDParam(r, store.EntityWebhook)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	if err := s.DeleteWebhook(r.Context(), id); err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the webhook's delivery log, newest first.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request, s store.Store) {
+	id, errs := parseIDParam(r, store.EntityWebhook)
+	limit, offset, paginationErrs := parsePagination(r)
+	errs = append(errs, paginationErrs...)
+	if len(errs) > 0 {
+		utils.SendFieldErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	deliveries, total, err := s.ListWebhookDeliveries(r.Context(), id, limit, offset)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	response := models.WebhookDeliveryListResponse{
+		Deliveries: make([]models.WebhookDelivery, len(deliveries)),
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	}
+	for i, d := range deliveries {
+		response.Deliveries[i] = models.WebhookDelivery{
+			ID:             d.ID,
+			EventType:      d.EventType,
+			NotificationID: d.NotificationID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt,
+			DeliveredAt:    d.DeliveredAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func webhookResponse(webhook store.Webhook) models.Webhook {
+	return models.Webhook{
+		ID:         webhook.ID,
+		URL:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		CreatedAt:  webhook.CreatedAt,
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWebhooks(t *testing.T) {
+	s := newSeededStore(t)
+	s.Now = func() time.Time { return time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC) }
+
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks", func(w http.ResponseWriter, r *http.Request) { CreateWebhook(w, r, s) }).Methods("POST")
+	router.HandleFunc("/webhooks", func(w http.ResponseWriter, r *http.Request) { ListWebhooks(w, r, s) }).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) { GetWebhook(w, r, s) }).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) { DeleteWebhook(w, r, s) }).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", func(w http.ResponseWriter, r *http.Request) { ListWebhookDeliveries(w, r, s) }).Methods("GET")
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Create Webhook", func(t *testing.T) {
+		rr := serve("POST", "/webhooks",
+			`{"url": "https://lms.example.com/hooks", "secret": "a-very-long-webhook-secret", "eventTypes": ["notification.sent"]}`)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status %d; got %d", http.StatusCreated, rr.Code)
+		}
+		expectedResponse := `{"id":1,"url":"https://lms.example.com/hooks","eventTypes":["notification.sent"],"createdAt":"2024-03-01T09:00:00Z"}`
+		if strings.TrimSpace(rr.Body.String()) != expectedResponse {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Webhook", func(t *testing.T) {
+		rr := serve("POST", "/webhooks", `{"url": "lms.example.com", "secret": "short", "eventTypes": ["student.suspended"]}`)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d; got %d", http.StatusBadRequest, rr.Code)
+		}
+		for _, expected := range []string{
+			`{"field":"url","message":"must be an absolute http or https URL"}`,
+			`{"field":"secret","message":"must be at least 16 characters"}`,
+			`{"field":"eventTypes[0]","message":"must be one of notification.sent"}`,
+		} {
+			if !strings.Contains(rr.Body.String(), expected) {
+				t.Errorf("Expected %s in %s", expected, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("List Webhooks", func(t *testing.T) {
+		rr := serve("GET", "/webhooks", "")
+
+		if !strings.Contains(rr.Body.String(), `"url":"https://lms.example.com/hooks"`) || strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("Expected the webhook without its secret; got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("Delivery Log", func(t *testing.T) {
+		if _, err := s.CreateNotification(context.Background(), "teacherken@gmail.com", "Exam on Monday", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		rr := serve("GET", "/webhooks/1/deliveries", "")
+
+		expectedResponse := `{"deliveries":[{"id":1,"eventType":"notification.sent","notificationId":1,"status":"pending","attempts":0,` +
+			`"createdAt":"2024-03-01T09:00:00Z"}],"total":1,"limit":50,"offset":0}`
+		if strings.TrimSpace(rr.Body.String()) != expectedResponse {
+			t.Errorf("Expected response body %s; got %s", expectedResponse, rr.Body.String())
+		}
+	})
+
+	t.Run("Delete Webhook", func(t *testing.T) {
+		if rr := serve("DELETE", "/webhooks/1", ""); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d; got %d", http.StatusNoContent, rr.Code)
+		}
+
+		rr := serve("GET", "/webhooks/1/deliveries", "")
+		expectedResponse := `"code":"webhook_not_found"`
+		if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected status %d and %s; got %d %s", http.StatusNotFound, expectedResponse, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		rr := serve("GET", "/webhooks/abc", "")
+
+		expectedResponse := `{"field":"id","message":"must be a webhook ID"}`
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), expectedResponse) {
+			t.Errorf("Expected status %d and %s; got %d %s", http.StatusBadRequest, expectedResponse, rr.Code, rr.Body.String())
+		}
+	})
+}
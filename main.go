@@ -1,33 +1,90 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"net/http"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/config"
 	"github.com/leeshuoan/gds-OneCV/db"
-	"github.com/leeshuoan/gds-OneCV/handlers"
+	"github.com/leeshuoan/gds-OneCV/delivery"
+	"github.com/leeshuoan/gds-OneCV/metrics"
+	"github.com/leeshuoan/gds-OneCV/ratelimit"
+	"github.com/leeshuoan/gds-OneCV/server"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/sweeper"
 )
 
 func main() {
-	router := mux.NewRouter()
-	db := db.OpenConnection()
-	defer db.Close()
-
-	router.HandleFunc("/api/register", func(w http.ResponseWriter, r *http.Request) {
-		handlers.Register(w, r, db)
-	}).Methods("POST")
-	router.HandleFunc("/api/commonstudents", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CommonStudents(w, r, db)
-	}).Methods("GET")
-	router.HandleFunc("/api/suspend", func(w http.ResponseWriter, r *http.Request) {
-		handlers.Suspend(w, r, db)
-	}).Methods("POST")
-	router.HandleFunc("/api/retrievefornotifications", func(w http.ResponseWriter, r *http.Request) {
-		handlers.RetrieveForNotifications(w, r, db)
-	}).Methods("POST")
-
-	fmt.Println("Server at 8080")
-	log.Fatal(http.ListenAndServe(":8000", router))
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "apikey" {
+		runAPIKey(cfg, args[1:])
+		return
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := db.OpenConnection(ctx, cfg.Database)
+	if err != nil {
+		logger.Error("connecting to the database", "err", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	m := metrics.New()
+	m.RegisterDBStats(conn)
+	s := metrics.InstrumentStore(store.NewPostgres(conn), m)
+
+	// Notifications are only queued for delivery while the workers that send
+	// them are enabled.
+	var pool *delivery.Pool
+	var channels []string
+	if cfg.Delivery.Enabled {
+		pool = delivery.NewPool(s, cfg.Delivery, delivery.NewSMTP(cfg.Delivery.SMTP))
+		channels = pool.Channels()
+	}
+
+	var limiter ratelimit.Limiter = ratelimit.NewMemory()
+	var postgresLimiter *ratelimit.Postgres
+	if cfg.RateLimit.Backend == config.RateLimitBackendPostgres {
+		postgresLimiter = ratelimit.NewPostgres(conn)
+		limiter = postgresLimiter
+	}
+
+	handler := newHandler(cfg, s, conn, m, limiter, channels, logger)
+	srv := server.New(cfg.Server, handler)
+	srv.Go(func(ctx context.Context) {
+		sweeper.Run(ctx, s, time.Duration(cfg.Server.SweepInterval))
+	})
+	if pool != nil {
+		srv.Go(pool.Run)
+	}
+	srv.Go(delivery.NewWebhooks(s, cfg.Webhooks).Run)
+	if postgresLimiter != nil {
+		srv.Go(func(ctx context.Context) {
+			postgresLimiter.PruneEvery(ctx, time.Hour)
+		})
+	}
+
+	logger.Info("server listening", "addr", cfg.Server.ListenAddr)
+	if err := srv.Run(ctx); err != nil {
+		logger.Error("server stopped with error", "err", err)
+	}
+	logger.Info("server stopped")
 }
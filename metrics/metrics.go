@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are the instruments exported on /metrics. Each Metrics has its own
+// registry, so that tests can create as many as they need.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	Requests        *prometheus.CounterVec
+	RequestDuration *prometheus.HistogramVec
+	StoreDuration   *prometheus.HistogramVec
+
+	RegistrationsCreated   prometheus.Counter
+	SuspensionsApplied     prometheus.Counter
+	SuspensionsExpired     prometheus.Counter
+	NotificationRecipients prometheus.Counter
+
+	NotificationsDelivered       prometheus.Counter
+	NotificationDeliveriesFailed prometheus.Counter
+	WebhooksDelivered            prometheus.Counter
+	WebhookDeliveriesFailed      prometheus.Counter
+}
+
+func New() *Metrics {
+	r := prometheus.NewRegistry()
+	r.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	f := promauto.With(r)
+
+	counter := func(name, help string) prometheus.Counter {
+		return f.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	}
+	return &Metrics{
+		Registry: r,
+
+		Requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "onecv_http_requests_total",
+			Help: "HTTP requests served, by route template and status.",
+		}, []string{"method", "route", "status"}),
+		RequestDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "onecv_http_request_duration_seconds",
+			Help: "Time taken to serve HTTP requests, by route template and status.",
+		}, []string{"method", "route", "status"}),
+		StoreDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "onecv_store_operation_duration_seconds",
+			Help: "Time taken by store operations, which is dominated by their database queries.",
+		}, []string{"operation", "outcome"}),
+
+		RegistrationsCreated: counter("onecv_registrations_created_total",
+			"Students registered to a teacher."),
+		SuspensionsApplied: counter("onecv_suspensions_applied_total",
+			"Suspensions opened for students that were not already suspended."),
+		SuspensionsExpired: counter("onecv_suspensions_expired_total",
+			"Time-boxed suspensions ended by the sweeper."),
+		NotificationRecipients: counter("onecv_notification_recipients_resolved_total",
+			"Recipients returned for notifications."),
+
+		NotificationsDelivered: counter("onecv_notifications_delivered_total",
+			"Notifications delivered to a recipient over a channel."),
+		NotificationDeliveriesFailed: counter("onecv_notification_deliveries_failed_total",
+			"Notification deliveries given up on after a permanent error or too many attempts."),
+		WebhooksDelivered: counter("onecv_webhooks_delivered_total",
+			"Webhook events accepted by their receiver."),
+		WebhookDeliveriesFailed: counter("onecv_webhook_deliveries_failed_total",
+			"Webhook events given up on after a permanent error or too many attempts."),
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDBStats exports the connection pool statistics of db.
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	f := promauto.With(m.Registry)
+	gauge := func(name, help string, fn func(sql.DBStats) float64) {
+		f.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 { return fn(db.Stats()) })
+	}
+	counter := func(name, help string, fn func(sql.DBStats) float64) {
+		f.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 { return fn(db.Stats()) })
+	}
+
+	gauge("onecv_db_max_open_connections", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("onecv_db_open_connections", "Established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("onecv_db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("onecv_db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("onecv_db_wait_count_total", "Connections waited for because the pool was exhausted.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("onecv_db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("onecv_db_max_idle_closed_total", "Connections closed because of the idle connection limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("onecv_db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
+
+// ObserveStore records how long a store operation took since start.
+func (m *Metrics) ObserveStore(operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.StoreDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/mocks"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// exposition scrapes m the way Prometheus would.
+func exposition(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d; got %d", http.StatusOK, rr.Code)
+	}
+	return rr.Body.String()
+}
+
+func TestInstrumentStore(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	s := InstrumentStore(store.NewMemory(), m)
+
+	s.CreateTeacher(ctx, "teacherken@gmail.com")
+	s.CreateStudents(ctx, []string{"studentjon@gmail.com", "studenthon@gmail.com"})
+	s.RegisterStudents(ctx, "teacherken@gmail.com", []string{"studentjon@gmail.com", "studenthon@gmail.com"}, false)
+	s.SuspendStudent(ctx, store.SuspendParams{Student: "studentjon@gmail.com"})
+	s.SuspendStudent(ctx, store.SuspendParams{Student: "studentjon@gmail.com"})
+	notification, _ := s.CreateNotification(ctx, "teacherken@gmail.com", "Hello", nil, []string{"email", "sms"})
+	deliveries, _ := s.ClaimDeliveries(ctx, []string{"email", "sms"}, 2, time.Minute)
+	s.CompleteDelivery(ctx, deliveries[0].ID)
+	s.FailDelivery(ctx, deliveries[1].ID, "mailbox unavailable")
+	s.CompleteDelivery(ctx, notification.ID+100)
+	s.GetTeacher(ctx, "unknown@gmail.com")
+
+	out := exposition(t, m)
+	for _, expected := range []string{
+		"onecv_registrations_created_total 2\n",
+		"onecv_suspensions_applied_total 1\n",
+		"onecv_notification_recipients_resolved_total 1\n",
+		"onecv_notifications_delivered_total 1\n",
+		"onecv_notification_deliveries_failed_total 1\n",
+		`onecv_store_operation_duration_seconds_count{operation="RegisterStudents",outcome="ok"} 1`,
+		`onecv_store_operation_duration_seconds_count{operation="SuspendStudent",outcome="ok"} 2`,
+		`onecv_store_operation_duration_seconds_count{operation="GetTeacher",outcome="error"} 1`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected exposition to contain %s; got\n%s", expected, out)
+		}
+	}
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	db, _ := mocks.NewMock()
+	defer db.Close()
+	m := New()
+	m.RegisterDBStats(db)
+
+	out := exposition(t, m)
+	for _, expected := range []string{
+		"# TYPE onecv_db_open_connections gauge\n",
+		"# TYPE onecv_db_wait_count_total counter\n",
+		"onecv_db_max_open_connections 0\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected exposition to contain %s; got\n%s", expected, out)
+		}
+	}
+}
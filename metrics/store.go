@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// Store times every operation of the wrapped store and counts the domain
+// events it carries out. Methods it does not override pass straight through.
+type Store struct {
+	store.Store
+	m *Metrics
+}
+
+func InstrumentStore(s store.Store, m *Metrics) *Store {
+	return &Store{Store: s, m: m}
+}
+
+func (s *Store) CreateTeacher(ctx context.Context, email string) (err error) {
+	defer s.observe("CreateTeacher", time.Now(), &err)
+	return s.Store.CreateTeacher(ctx, email)
+}
+
+func (s *Store) TeacherExists(ctx context.Context, email string) (exists bool, err error) {
+	defer s.observe("TeacherExists", time.Now(), &err)
+	return s.Store.TeacherExists(ctx, email)
+}
+
+func (s *Store) GetTeacher(ctx context.Context, email string) (teacher store.Teacher, err error) {
+	defer s.observe("GetTeacher", time.Now(), &err)
+	return s.Store.GetTeacher(ctx, email)
+}
+
+func (s *Store) ListTeachers(ctx context.Context, limit, offset int) (teachers []string, total int, err error) {
+	defer s.observe("ListTeachers", time.Now(), &err)
+	return s.Store.ListTeachers(ctx, limit, offset)
+}
+
+func (s *Store) UpdateTeacher(ctx context.Context, email, newEmail string) (err error) {
+	defer s.observe("UpdateTeacher", time.Now(), &err)
+	return s.Store.UpdateTeacher(ctx, email, newEmail)
+}
+
+func (s *Store) DeleteTeacher(ctx context.Context, email string, removeRegistrations bool) (err error) {
+	defer s.observe("DeleteTeacher", time.Now(), &err)
+	return s.Store.DeleteTeacher(ctx, email, removeRegistrations)
+}
+
+func (s *Store) CreateStudent(ctx context.Context, email string) (err error) {
+	defer s.observe("CreateStudent", time.Now(), &err)
+	return s.Store.CreateStudent(ctx, email)
+}
+
+func (s *Store) CreateStudents(ctx context.Context, emails []string) (created, existing []string, err error) {
+	defer s.observe("CreateStudents", time.Now(), &err)
+	return s.Store.CreateStudents(ctx, emails)
+}
+
+func (s *Store) GetStudent(ctx context.Context, email string) (student store.Student, err error) {
+	defer s.observe("GetStudent", time.Now(), &err)
+	return s.Store.GetStudent(ctx, email)
+}
+
+func (s *Store) ListStudents(ctx context.Context, filter store.StudentFilter, limit, offset int) (students []store.Student, total int, err error) {
+	defer s.observe("ListStudents", time.Now(), &err)
+	return s.Store.ListStudents(ctx, filter, limit, offset)
+}
+
+func (s *Store) UpdateStudent(ctx context.Context, email, newEmail string) (err error) {
+	defer s.observe("UpdateStudent", time.Now(), &err)
+	return s.Store.UpdateStudent(ctx, email, newEmail)
+}
+
+func (s *Store) DeleteStudent(ctx context.Context, email string, removeRegistrations bool) (err error) {
+	defer s.observe("DeleteStudent", time.Now(), &err)
+	return s.Store.DeleteStudent(ctx, email, removeRegistrations)
+}
+
+func (s *Store) RegisterStudents(ctx context.Context, teacher string, students []string, partial bool) (result store.RegistrationResult, err error) {
+	defer s.observe("RegisterStudents", time.Now(), &err)
+	result, err = s.Store.RegisterStudents(ctx, teacher, students, partial)
+	if err == nil {
+		s.m.RegistrationsCreated.Add(float64(len(result.Registered)))
+	}
+	return result, err
+}
+
+func (s *Store) CommonStudents(ctx context.Context, query store.CommonStudentsQuery) (students []store.CommonStudent, total int, err error) {
+	defer s.observe("CommonStudents", time.Now(), &err)
+	return s.Store.CommonStudents(ctx, query)
+}
+
+func (s *Store) DeregisterStudents(ctx context.Context, teacher string, students []string) (result store.DeregistrationResult, err error) {
+	defer s.observe("DeregisterStudents", time.Now(), &err)
+	return s.Store.DeregisterStudents(ctx, teacher, students)
+}
+
+func (s *Store) DeregisterAll(ctx context.Context, teacher string) (students []string, err error) {
+	defer s.observe("DeregisterAll", time.Now(), &err)
+	return s.Store.DeregisterAll(ctx, teacher)
+}
+
+func (s *Store) SuspendStudent(ctx context.Context, params store.SuspendParams) (alreadySuspended bool, err error) {
+	defer s.observe("SuspendStudent", time.Now(), &err)
+	alreadySuspended, err = s.Store.SuspendStudent(ctx, params)
+	if err == nil && !alreadySuspended {
+		s.m.SuspensionsApplied.Inc()
+	}
+	return alreadySuspended, err
+}
+
+func (s *Store) UnsuspendStudent(ctx context.Context, student string) (wasSuspended bool, err error) {
+	defer s.observe("UnsuspendStudent", time.Now(), &err)
+	return s.Store.UnsuspendStudent(ctx, student)
+}
+
+func (s *Store) SuspensionHistory(ctx context.Context, student string) (history []store.Suspension, err error) {
+	defer s.observe("SuspensionHistory", time.Now(), &err)
+	return s.Store.SuspensionHistory(ctx, student)
+}
+
+func (s *Store) ExpireSuspensions(ctx context.Context) (expired []string, err error) {
+	defer s.observe("ExpireSuspensions", time.Now(), &err)
+	expired, err = s.Store.ExpireSuspensions(ctx)
+	s.m.SuspensionsExpired.Add(float64(len(expired)))
+	return expired, err
+}
+
+func (s *Store) CreateNotification(ctx context.Context, teacher, message string, mentioned []string, channels []string) (notification store.Notification, err error) {
+	defer s.observe("CreateNotification", time.Now(), &err)
+	notification, err = s.Store.CreateNotification(ctx, teacher, message, mentioned, channels)
+	s.m.NotificationRecipients.Add(float64(len(notification.Recipients)))
+	return notification, err
+}
+
+func (s *Store) GetNotification(ctx context.Context, id int64) (notification store.Notification, err error) {
+	defer s.observe("GetNotification", time.Now(), &err)
+	return s.Store.GetNotification(ctx, id)
+}
+
+func (s *Store) ListNotifications(ctx context.Context, filter store.NotificationFilter, limit, offset int) (notifications []store.Notification, total int, err error) {
+	defer s.observe("ListNotifications", time.Now(), &err)
+	return s.Store.ListNotifications(ctx, filter, limit, offset)
+}
+
+func (s *Store) ClaimDeliveries(ctx context.Context, channels []string, limit int, lease time.Duration) (deliveries []store.Delivery, err error) {
+	defer s.observe("ClaimDeliveries", time.Now(), &err)
+	return s.Store.ClaimDeliveries(ctx, channels, limit, lease)
+}
+
+func (s *Store) CompleteDelivery(ctx context.Context, id int64) (err error) {
+	defer s.observe("CompleteDelivery", time.Now(), &err)
+	err = s.Store.CompleteDelivery(ctx, id)
+	if err == nil {
+		s.m.NotificationsDelivered.Inc()
+	}
+	return err
+}
+
+func (s *Store) RetryDelivery(ctx context.Context, id int64, lastError string, retryAt time.Time) (err error) {
+	defer s.observe("RetryDelivery", time.Now(), &err)
+	return s.Store.RetryDelivery(ctx, id, lastError, retryAt)
+}
+
+func (s *Store) FailDelivery(ctx context.Context, id int64, lastError string) (err error) {
+	defer s.observe("FailDelivery", time.Now(), &err)
+	err = s.Store.FailDelivery(ctx, id, lastError)
+	if err == nil {
+		s.m.NotificationDeliveriesFailed.Inc()
+	}
+	return err
+}
+
+func (s *Store) ListDeliveries(ctx context.Context, notificationID int64) (deliveries []store.Delivery, err error) {
+	defer s.observe("ListDeliveries", time.Now(), &err)
+	return s.Store.ListDeliveries(ctx, notificationID)
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook store.Webhook) (created store.Webhook, err error) {
+	defer s.observe("CreateWebhook", time.Now(), &err)
+	return s.Store.CreateWebhook(ctx, webhook)
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int64) (webhook store.Webhook, err error) {
+	defer s.observe("GetWebhook", time.Now(), &err)
+	return s.Store.GetWebhook(ctx, id)
+}
+
+func (s *Store) ListWebhooks(ctx context.Context) (webhooks []store.Webhook, err error) {
+	defer s.observe("ListWebhooks", time.Now(), &err)
+	return s.Store.ListWebhooks(ctx)
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id int64) (err error) {
+	defer s.observe("DeleteWebhook", time.Now(), &err)
+	return s.Store.DeleteWebhook(ctx, id)
+}
+
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (deliveries []store.WebhookDelivery, err error) {
+	defer s.observe("ClaimWebhookDeliveries", time.Now(), &err)
+	return s.Store.ClaimWebhookDeliveries(ctx, limit, lease)
+}
+
+func (s *Store) CompleteWebhookDelivery(ctx context.Context, id int64, responseStatus int) (err error) {
+	defer s.observe("CompleteWebhookDelivery", time.Now(), &err)
+	err = s.Store.CompleteWebhookDelivery(ctx, id, responseStatus)
+	if err == nil {
+		s.m.WebhooksDelivered.Inc()
+	}
+	return err
+}
+
+func (s *Store) RetryWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string, retryAt time.Time) (err error) {
+	defer s.observe("RetryWebhookDelivery", time.Now(), &err)
+	return s.Store.RetryWebhookDelivery(ctx, id, responseStatus, lastError, retryAt)
+}
+
+func (s *Store) FailWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string) (err error) {
+	defer s.observe("FailWebhookDelivery", time.Now(), &err)
+	err = s.Store.FailWebhookDelivery(ctx, id, responseStatus, lastError)
+	if err == nil {
+		s.m.WebhookDeliveriesFailed.Inc()
+	}
+	return err
+}
+
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) (deliveries []store.WebhookDelivery, total int, err error) {
+	defer s.observe("ListWebhookDeliveries", time.Now(), &err)
+	return s.Store.ListWebhookDeliveries(ctx, webhookID, limit, offset)
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, key store.APIKey, tokenHash string) (created store.APIKey, err error) {
+	defer s.observe("CreateAPIKey", time.Now(), &err)
+	return s.Store.CreateAPIKey(ctx, key, tokenHash)
+}
+
+func (s *Store) LookupAPIKey(ctx context.Context, tokenHash string) (key store.APIKey, err error) {
+	defer s.observe("LookupAPIKey", time.Now(), &err)
+	return s.Store.LookupAPIKey(ctx, tokenHash)
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	defer s.observe("RevokeAPIKey", time.Now(), &err)
+	return s.Store.RevokeAPIKey(ctx, id)
+}
+
+func (s *Store) observe(operation string, start time.Time, err *error) {
+	s.m.ObserveStore(operation, start, *err)
+}
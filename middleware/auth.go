@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/store"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// Authenticate rejects requests without a valid API key, sent either as
+// "Authorization: Bearer <key>" or in the X-API-Key header, and makes the
+// caller's identity available through auth.FromContext.
+func Authenticate(keys store.AuthStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := apiKey(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				utils.SendJSONError(w, http.StatusUnauthorized, "An API key is required as 'Authorization: Bearer <key>'")
+				return
+			}
+
+			key, err := keys.LookupAPIKey(r.Context(), auth.HashToken(token))
+			if errors.Is(err, store.ErrNotFound) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				utils.SendJSONError(w, http.StatusUnauthorized, "The API key is invalid or has been revoked")
+				return
+			}
+			if err != nil {
+				slog.Error("looking up API key", "requestId", GetRequestID(r.Context()), "err", err)
+				utils.SendJSONError(w, http.StatusInternalServerError, "Could not verify the API key")
+				return
+			}
+
+			identity := auth.Identity{KeyID: key.ID, Name: key.Name, Role: key.Role, Teacher: key.Teacher, Permissions: key.Permissions}
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+func apiKey(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// Require serves next only to callers whose role grants permission. It must
+// run after Authenticate.
+func Require(permission string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.FromContext(r.Context())
+		if !ok {
+			utils.SendJSONError(w, http.StatusUnauthorized, "Authentication is required")
+			return
+		}
+		if !identity.Can(permission) {
+			utils.SendJSONError(w, http.StatusForbidden, fmt.Sprintf("The %s role lacks the %s permission", identity.Role, permission))
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.CreateTeacher(ctx, "teacherken@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.CreateAPIKey(ctx, store.APIKey{Role: auth.RoleTeacher, Teacher: "teacherken@gmail.com"}, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedToken, revokedHash, _ := auth.NewToken()
+	revoked, err := s.CreateAPIKey(ctx, store.APIKey{Role: auth.RoleAdmin}, revokedHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeAPIKey(ctx, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var identity auth.Identity
+	handler := Authenticate(s)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = auth.FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name           string
+		header, value  string
+		expectedStatus int
+	}{
+		{"Bearer Token", "Authorization", "Bearer " + token, http.StatusOK},
+		{"API Key Header", "X-API-Key", token, http.StatusOK},
+		{"Missing Key", "", "", http.StatusUnauthorized},
+		{"Unknown Key", "Authorization", "Bearer onecv_unknown", http.StatusUnauthorized},
+		{"Revoked Key", "Authorization", "Bearer " + revokedToken, http.StatusUnauthorized},
+		{"Other Scheme", "Authorization", "Basic " + token, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity = auth.Identity{}
+			req := httptest.NewRequest("GET", "/api/students", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d; got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && (identity.KeyID != key.ID || identity.Teacher != "teacherken@gmail.com") {
+				t.Errorf("Expected identity of key %d for teacherken@gmail.com; got %+v", key.ID, identity)
+			}
+			if tt.expectedStatus == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s := store.NewMemory()
+	handler := Require(auth.PermissionSuspensionsWrite, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{"Admin", auth.RoleAdmin, http.StatusNoContent},
+		{"Teacher", auth.RoleTeacher, http.StatusForbidden},
+		{"Auditor", auth.RoleAuditor, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := auth.Identity{Role: tt.role, Permissions: s.RolePermissions[tt.role]}
+			req := httptest.NewRequest("POST", "/api/suspend", nil)
+			req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d; got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusForbidden && !strings.Contains(rr.Body.String(), auth.PermissionSuspensionsWrite) {
+				t.Errorf("Expected the error to name %s; got %s", auth.PermissionSuspensionsWrite, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/suspend", nil))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d; got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
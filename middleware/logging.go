@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type requestLogKey struct{}
+
+// requestLog collects details that only the handler knows, such as the
+// teacher a request acts for, so that Logging can include them.
+type requestLog struct {
+	teacher string
+}
+
+// SetTeacher records the teacher a request acts for in the request log line.
+// It does nothing outside of Logging.
+func SetTeacher(ctx context.Context, teacher string) {
+	if entry, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		entry.teacher = teacher
+	}
+}
+
+// Logging writes one structured line per request served by next. Wrap it in
+// RequestID and Route so that the line carries the request ID and route.
+func Logging(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{}
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, entry)))
+
+		status := recorder.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("requestId", GetRequestID(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("route", GetRoute(r.Context())),
+			slog.Int("status", status),
+			slog.Float64("latencyMs", float64(time.Since(start).Microseconds())/1000),
+		}
+		if entry.teacher != "" {
+			attrs = append(attrs, slog.String("teacher", entry.teacher))
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+func newLoggedRouter(logs *bytes.Buffer) http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/teachers/{email}", func(w http.ResponseWriter, r *http.Request) {
+		SetTeacher(r.Context(), mux.Vars(r)["email"])
+		utils.SendJSONError(w, http.StatusNotFound, "Teacher does not exist")
+	}).Methods("GET")
+	router.HandleFunc("/api/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}).Methods("GET")
+
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	return RequestID(Route(router, Logging(logger, router)))
+}
+
+func decodeLogLine(t *testing.T, logs *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line; got %q", logs.String())
+	}
+	return line
+}
+
+func TestLogging(t *testing.T) {
+	t.Run("Logs Route Status And Teacher", func(t *testing.T) {
+		var logs bytes.Buffer
+		req := httptest.NewRequest("GET", "/api/teachers/teacherken@gmail.com", nil)
+		rr := httptest.NewRecorder()
+
+		newLoggedRouter(&logs).ServeHTTP(rr, req)
+
+		line := decodeLogLine(t, &logs)
+		expected := map[string]any{
+			"method":  "GET",
+			"route":   "/api/teachers/{email}",
+			"status":  float64(http.StatusNotFound),
+			"teacher": "teacherken@gmail.com",
+		}
+		for key, value := range expected {
+			if line[key] != value {
+				t.Errorf("Expected log field %s to be %v; got %v", key, value, line[key])
+			}
+		}
+		if _, ok := line["latencyMs"]; !ok {
+			t.Error("Expected log line to include latencyMs")
+		}
+
+		requestID := rr.Header().Get(utils.RequestIDHeader)
+		if requestID == "" {
+			t.Fatal("Expected a generated X-Request-ID header")
+		}
+		if line["requestId"] != requestID {
+			t.Errorf("Expected log requestId %s; got %v", requestID, line["requestId"])
+		}
+		expectedBody := `"requestId":"` + requestID + `"`
+		if !strings.Contains(rr.Body.String(), expectedBody) {
+			t.Errorf("Expected error body to contain %s; got %s", expectedBody, rr.Body.String())
+		}
+	})
+
+	t.Run("Propagates Caller Request ID", func(t *testing.T) {
+		var logs bytes.Buffer
+		req := httptest.NewRequest("GET", "/api/ok", nil)
+		req.Header.Set(utils.RequestIDHeader, "caller-id-123")
+		rr := httptest.NewRecorder()
+
+		newLoggedRouter(&logs).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get(utils.RequestIDHeader); got != "caller-id-123" {
+			t.Errorf("Expected X-Request-ID caller-id-123; got %s", got)
+		}
+		line := decodeLogLine(t, &logs)
+		if line["requestId"] != "caller-id-123" || line["status"] != float64(http.StatusOK) {
+			t.Errorf("Expected requestId caller-id-123 and status 200; got %v and %v", line["requestId"], line["status"])
+		}
+		if _, ok := line["teacher"]; ok {
+			t.Error("Expected no teacher field when the handler does not set one")
+		}
+	})
+
+	t.Run("Replaces Unusable Request ID", func(t *testing.T) {
+		var logs bytes.Buffer
+		req := httptest.NewRequest("GET", "/api/ok", nil)
+		req.Header.Set(utils.RequestIDHeader, "has spaces\n")
+		rr := httptest.NewRecorder()
+
+		newLoggedRouter(&logs).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get(utils.RequestIDHeader); got == "" || got == "has spaces\n" {
+			t.Errorf("Expected a generated X-Request-ID; got %q", got)
+		}
+	})
+
+	t.Run("Unmatched Route", func(t *testing.T) {
+		var logs bytes.Buffer
+		req := httptest.NewRequest("GET", "/api/unknown", nil)
+		rr := httptest.NewRecorder()
+
+		newLoggedRouter(&logs).ServeHTTP(rr, req)
+
+		line := decodeLogLine(t, &logs)
+		if line["route"] != "unmatched" || line["status"] != float64(http.StatusNotFound) {
+			t.Errorf("Expected route unmatched with status 404; got %v with %v", line["route"], line["status"])
+		}
+	})
+}
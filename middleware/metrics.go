@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/metrics"
+)
+
+// Metrics counts and times the requests served by next by route and status.
+// Wrap it in Route so that requests are grouped by route template.
+func Metrics(m *metrics.Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		route := GetRoute(r.Context())
+		status := strconv.Itoa(recorder.Status())
+		m.Requests.WithLabelValues(r.Method, route, status).Inc()
+		m.RequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
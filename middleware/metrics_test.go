@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/teachers/{email}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	router.Handle("/metrics", m.Handler()).Methods("GET")
+	handler := Route(router, Metrics(m, router))
+
+	for _, path := range []string{"/api/teachers/a@gmail.com", "/api/teachers/b@gmail.com", "/api/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d; got %d", http.StatusOK, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected Prometheus text content type; got %s", contentType)
+	}
+	for _, expected := range []string{
+		`onecv_http_requests_total{method="GET",route="/api/teachers/{email}",status="404"} 2`,
+		`onecv_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`onecv_http_request_duration_seconds_count{method="GET",route="/api/teachers/{email}",status="404"} 2`,
+	} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("Expected metrics to contain %s; got\n%s", expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/ratelimit"
+	"github.com/leeshuoan/gds-OneCV/utils"
+)
+
+// RateLimit limits each client to the rate configured for the route,
+// answering 429 once its bucket is empty. Clients are told apart by API key
+// when Authenticate has run, and otherwise by IP address. If the limiter
+// fails the request is let through rather than failing the API.
+func RateLimit(limiter ratelimit.Limiter, cfg config.RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := GetRoute(r.Context())
+			key := r.Method + " " + route + " " + clientKey(r)
+			if allow(w, r, limiter, key, cfg.For(r.Method, route)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// RateLimitByIP limits all requests from each IP address to limit, whether
+// or not they carry a valid API key. It runs before Authenticate, so that
+// requests with missing or invalid keys are throttled before their keys are
+// looked up.
+func RateLimitByIP(limiter ratelimit.Limiter, limit config.RateLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allow(w, r, limiter, "all "+clientIP(r), limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow takes a request from the bucket stored under key, sets the
+// RateLimit headers and answers 429 if the bucket is empty.
+func allow(w http.ResponseWriter, r *http.Request, limiter ratelimit.Limiter, key string, limit config.RateLimit) bool {
+	decision, err := limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		slog.Error("rate limiting failed, allowing request", "requestId", GetRequestID(r.Context()), "key", key, "err", err)
+		return true
+	}
+
+	window := float64(limit.Burst) / limit.RequestsPerMinute * 60
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(window))))
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", ceilSeconds(decision.Reset))
+
+	if !decision.Allowed {
+		retryAfter := ceilSeconds(decision.RetryAfter)
+		w.Header().Set("Retry-After", retryAfter)
+		utils.SendJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many requests; retry in %s seconds", retryAfter))
+		return false
+	}
+	return true
+}
+
+func clientKey(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + strconv.FormatInt(identity.KeyID, 10)
+	}
+	return "ip:" + clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
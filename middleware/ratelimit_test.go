@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimit{RequestsPerMinute: 600, Burst: 10},
+		Routes: map[string]config.RateLimit{
+			"POST /api/retrievefornotifications": {RequestsPerMinute: 6, Burst: 2},
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Use(RateLimit(ratelimit.NewMemory(), cfg))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/api/retrievefornotifications", ok).Methods("POST")
+	router.HandleFunc("/api/students", ok).Methods("GET")
+	handler := Route(router, router)
+
+	send := func(method, path, remoteAddr string, keyID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		if keyID != 0 {
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{KeyID: keyID}))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Limits Route Per API Key", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if rr := send("POST", "/api/retrievefornotifications", "10.0.0.1:1000", 1); rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d; got %d", http.StatusOK, rr.Code)
+			}
+		}
+
+		rr := send("POST", "/api/retrievefornotifications", "10.0.0.1:1000", 1)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status %d; got %d", http.StatusTooManyRequests, rr.Code)
+		}
+		expectedHeaders := map[string]string{
+			"Retry-After":         "10",
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": "0",
+			"RateLimit-Reset":     "20",
+			"RateLimit-Policy":    "2;w=20",
+		}
+		for header, expected := range expectedHeaders {
+			if got := rr.Header().Get(header); got != expected {
+				t.Errorf("Expected %s %s; got %s", header, expected, got)
+			}
+		}
+
+		if rr := send("POST", "/api/retrievefornotifications", "10.0.0.1:1000", 2); rr.Code != http.StatusOK {
+			t.Errorf("Expected another API key from the same IP to be allowed; got %d", rr.Code)
+		}
+	})
+
+	t.Run("Other Routes Use Default", func(t *testing.T) {
+		rr := send("GET", "/api/students", "10.0.0.1:1000", 1)
+		if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "10" {
+			t.Errorf("Expected status 200 with the default limit of 10; got %d with %s", rr.Code, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("Limits Unauthenticated Clients By IP", func(t *testing.T) {
+		send("POST", "/api/retrievefornotifications", "10.0.0.2:1000", 0)
+		send("POST", "/api/retrievefornotifications", "10.0.0.2:2000", 0)
+
+		if rr := send("POST", "/api/retrievefornotifications", "10.0.0.2:3000", 0); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected status %d; got %d", http.StatusTooManyRequests, rr.Code)
+		}
+		if rr := send("POST", "/api/retrievefornotifications", "10.0.0.3:1000", 0); rr.Code != http.StatusOK {
+			t.Errorf("Expected another IP to be allowed; got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type routeKey struct{}
+
+// Route matches the request against router and records the template of the
+// matching route, such as /api/teachers/{email}, before passing the request
+// to next. Logging and Metrics group requests by this template so that the
+// emails in paths do not end up in logs or metric labels.
+func Route(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), routeKey{}, routeTemplate(router, r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRoute returns the route template recorded by Route, or "unmatched".
+func GetRoute(ctx context.Context) string {
+	if route, ok := ctx.Value(routeKey{}).(string); ok {
+		return route
+	}
+	return "unmatched"
+}
+
+func routeTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.Route == nil {
+		return "unmatched"
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return template
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Status is the status code written, which is 200 if the handler wrote
+// nothing at all.
+func (s *statusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/db"
+)
+
+const migrateUsage = `usage: gds-OneCV [flags] migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [n]   revert the latest n migrations (default 1)
+  status     show the applied and latest versions
+  collisions list teachers and students whose emails differ only in case
+  seed       insert the sample teachers, students and registrations`
+
+func runMigrate(cfg config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	conn, err := db.OpenConnection(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx, conn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("invalid number of migrations to revert: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(ctx, conn, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		current, err := db.CurrentVersion(ctx, conn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		latest, err := db.LatestVersion()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("current version %d, latest version %d\n", current, latest)
+	case "collisions":
+		collisions, err := db.EmailCollisions(ctx, conn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, collision := range collisions {
+			fmt.Printf("%s %s: %s\n", collision.Table, collision.Email, strings.Join(collision.Emails, ", "))
+		}
+		if len(collisions) == 0 {
+			fmt.Println("no emails differ only in case")
+		}
+	case "seed":
+		if err := db.Seed(ctx, conn); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("seeded sample data")
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -1,11 +1,36 @@
 package models
 
+import "time"
+
 type RegistrationRequest struct {
 	Teacher  string   `json:"teacher"`
 	Students []string `json:"students"`
+	Partial  bool     `json:"partial,omitempty"`
+}
+
+type DeregistrationRequest struct {
+	Teacher  string   `json:"teacher"`
+	Students []string `json:"students"`
+	All      bool     `json:"all,omitempty"`
+}
+
+type DeregistrationResponse struct {
+	Deregistered  []string `json:"deregistered"`
+	NotRegistered []string `json:"notRegistered"`
 }
 
 type SuspendRequest struct {
+	Student      string `json:"student"`
+	Reason       string `json:"reason,omitempty"`
+	Until        string `json:"until,omitempty"`
+	DurationDays int    `json:"durationDays,omitempty"`
+}
+
+type SuspendResponse struct {
+	AlreadySuspended bool `json:"alreadySuspended"`
+}
+
+type UnsuspendRequest struct {
 	Student string `json:"student"`
 }
 
@@ -14,10 +39,168 @@ type NotificationRequest struct {
 	Notification string `json:"notification"`
 }
 
+// CommonStudentsResponse only includes Details and Total when they were asked
+// for, and Next, the URL of the following page, while more students remain.
 type CommonStudentsResponse struct {
-	Students []string `json:"students"`
+	Students []string        `json:"students"`
+	Details  []CommonStudent `json:"details,omitempty"`
+	Total    *int            `json:"total,omitempty"`
+	Next     string          `json:"next,omitempty"`
+}
+
+// CommonStudent lists which of the requested teachers a student is
+// registered to.
+type CommonStudent struct {
+	Email     string   `json:"email"`
+	Teachers  []string `json:"teachers"`
+	Suspended bool     `json:"suspended"`
 }
 
 type NotificationResponse struct {
-	Recipients []string `json:"recipients"`
-}
\ No newline at end of file
+	Recipients     []string `json:"recipients"`
+	NotificationID int64    `json:"notificationId"`
+}
+
+// Notification leaves out Recipients and Deliveries when notifications are
+// listed.
+type Notification struct {
+	ID             int64      `json:"id"`
+	Teacher        string     `json:"teacher"`
+	Notification   string     `json:"notification"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	RecipientCount int        `json:"recipientCount"`
+	Recipients     []string   `json:"recipients,omitempty"`
+	Deliveries     []Delivery `json:"deliveries,omitempty"`
+}
+
+type Delivery struct {
+	Recipient   string     `json:"recipient"`
+	Channel     string     `json:"channel"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"lastError,omitempty"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+}
+
+type NotificationListResponse struct {
+	Notifications []Notification `json:"notifications"`
+	Total         int            `json:"total"`
+	Limit         int            `json:"limit"`
+	Offset        int            `json:"offset"`
+}
+
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// Webhook never includes the secret, which only the subscriber needs.
+type Webhook struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// WebhookDelivery is an entry in a webhook's delivery log. ResponseStatus is
+// left out when the latest attempt got no response.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	EventType      string     `json:"eventType"`
+	NotificationID int64      `json:"notificationId"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int               `json:"total"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
+
+type RegistrationResponse struct {
+	Registered        []string `json:"registered"`
+	AlreadyRegistered []string `json:"alreadyRegistered"`
+	UnknownStudents   []string `json:"unknownStudents"`
+}
+
+type Suspension struct {
+	SuspendedBy string     `json:"suspendedBy,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	StartedAt   time.Time  `json:"startedAt"`
+	EndsAt      *time.Time `json:"endsAt,omitempty"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	EndReason   string     `json:"endReason,omitempty"`
+}
+
+type SuspensionHistoryResponse struct {
+	Student        string       `json:"student"`
+	Suspended      bool         `json:"suspended"`
+	SuspendedUntil *time.Time   `json:"suspendedUntil,omitempty"`
+	Suspensions    []Suspension `json:"suspensions"`
+}
+
+type TeacherRequest struct {
+	Email string `json:"email"`
+}
+
+type TeacherResponse struct {
+	Email    string   `json:"email"`
+	Students []string `json:"students"`
+}
+
+type TeacherListResponse struct {
+	Teachers []string `json:"teachers"`
+	Total    int      `json:"total"`
+	Limit    int      `json:"limit"`
+	Offset   int      `json:"offset"`
+}
+
+type StudentRequest struct {
+	Email string `json:"email"`
+}
+
+type BulkStudentsRequest struct {
+	Students []string `json:"students"`
+}
+
+type BulkStudentsResponse struct {
+	Created       []string `json:"created"`
+	AlreadyExists []string `json:"alreadyExists"`
+}
+
+type StudentResponse struct {
+	Email          string     `json:"email"`
+	Suspended      bool       `json:"suspended"`
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+}
+
+type StudentListResponse struct {
+	Students []StudentResponse `json:"students"`
+	Total    int               `json:"total"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+}
+
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	Current   *int    `json:"currentVersion,omitempty"`
+	Expected  *int    `json:"expectedVersion,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
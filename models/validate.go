@@ -0,0 +1,168 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FieldError describes one problem with a request field. Field is a path
+// into the request body such as "students[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// Validator is implemented by every request body. Validate also rewrites the
+// request's emails into their canonical form.
+type Validator interface {
+	Validate() []FieldError
+}
+
+type fieldErrors []FieldError
+
+func (errs *fieldErrors) add(field, message string) {
+	*errs = append(*errs, FieldError{Field: field, Message: message})
+}
+
+// email checks a required email field and returns its canonical form.
+func (errs *fieldErrors) email(field, value string) string {
+	if strings.TrimSpace(value) == "" {
+		errs.add(field, "is required")
+		return value
+	}
+	email, ok := NormalizeEmail(value)
+	if !ok {
+		errs.add(field, "not a valid email")
+	}
+	return email
+}
+
+// emails checks a list of emails that must not be empty and canonicalizes it
+// in place.
+func (errs *fieldErrors) emails(field string, values []string) {
+	if len(values) == 0 {
+		errs.add(field, "must list at least one email")
+		return
+	}
+	for i, value := range values {
+		values[i] = errs.email(fmt.Sprintf("%s[%d]", field, i), value)
+	}
+}
+
+// NormalizeEmail returns the canonical form of an email address: trimmed and
+// lowercased, so that Teacher@Gmail.com and teacher@gmail.com are the same
+// person. It reports false unless value is a bare address such as
+// student@example.com, without a display name or angle brackets.
+func NormalizeEmail(value string) (string, bool) {
+	email := strings.ToLower(strings.TrimSpace(value))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email, "@") {
+		return value, false
+	}
+	return email, true
+}
+
+func (r *RegistrationRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Teacher = errs.email("teacher", r.Teacher)
+	errs.emails("students", r.Students)
+	return errs
+}
+
+func (r *DeregistrationRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Teacher = errs.email("teacher", r.Teacher)
+	switch {
+	case r.All && len(r.Students) > 0:
+		errs.add("all", "only one of 'students' and 'all' may be provided")
+	case !r.All:
+		errs.emails("students", r.Students)
+	}
+	return errs
+}
+
+func (r *SuspendRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Student = errs.email("student", r.Student)
+	if r.Until != "" && r.DurationDays != 0 {
+		errs.add("until", "only one of 'until' and 'durationDays' may be provided")
+	}
+	if r.Until != "" {
+		if _, err := time.Parse(time.RFC3339, r.Until); err != nil {
+			errs.add("until", "must be an RFC 3339 timestamp")
+		}
+	}
+	if r.DurationDays < 0 {
+		errs.add("durationDays", "must be a positive number of days")
+	}
+	return errs
+}
+
+func (r *UnsuspendRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Student = errs.email("student", r.Student)
+	return errs
+}
+
+func (r *NotificationRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Teacher = errs.email("teacher", r.Teacher)
+	if strings.TrimSpace(r.Notification) == "" {
+		errs.add("notification", "is required")
+	}
+	return errs
+}
+
+func (r *TeacherRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Email = errs.email("email", r.Email)
+	return errs
+}
+
+func (r *StudentRequest) Validate() []FieldError {
+	var errs fieldErrors
+	r.Email = errs.email("email", r.Email)
+	return errs
+}
+
+func (r *BulkStudentsRequest) Validate() []FieldError {
+	var errs fieldErrors
+	errs.emails("students", r.Students)
+	return errs
+}
+
+// WebhookEventTypes are the events that webhooks may subscribe to.
+var WebhookEventTypes = []string{"notification.sent"}
+
+// minWebhookSecretLength keeps webhook signatures from being guessed.
+const minWebhookSecretLength = 16
+
+func (r *WebhookRequest) Validate() []FieldError {
+	var errs fieldErrors
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("url", "must be an absolute http or https URL")
+	}
+	if len(r.Secret) < minWebhookSecretLength {
+		errs.add("secret", fmt.Sprintf("must be at least %d characters", minWebhookSecretLength))
+	}
+	if len(r.EventTypes) == 0 {
+		errs.add("eventTypes", "must list at least one event type")
+	}
+	for i, eventType := range r.EventTypes {
+		known := false
+		for _, t := range WebhookEventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			errs.add(fmt.Sprintf("eventTypes[%d]", i), "must be one of "+strings.Join(WebhookEventTypes, ", "))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+)
+
+// pruneEvery is how many requests the memory limiter serves between sweeps
+// for buckets that have refilled completely and can be forgotten.
+const pruneEvery = 1024
+
+// Memory keeps buckets in process memory. Each replica limits clients on its
+// own, so use Postgres when running more than one.
+type Memory struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	requests int
+
+	// Now is the clock used to refill buckets. Tests may replace it.
+	Now func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), Now: time.Now}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit config.RateLimit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	m.requests++
+	if m.requests%pruneEvery == 0 {
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	tokens, decision := take(b.tokens, now.Sub(b.updated), limit)
+	b.tokens = tokens
+	b.updated = now
+	b.full = now.Add(decision.Reset)
+	return decision, nil
+}
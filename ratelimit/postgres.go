@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+)
+
+// Postgres keeps buckets in the rate_limit_buckets table so that every
+// replica shares them. The database clock refills the buckets, so replicas
+// with skewed clocks agree.
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Allow(ctx context.Context, key string, limit config.RateLimit) (Decision, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+		INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (bucket_key) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, sqlStatement, key, limit.Burst); err != nil {
+		return Decision{}, err
+	}
+
+	var tokens, elapsed float64
+	query := `
+		SELECT tokens, EXTRACT(EPOCH FROM now() - updated_at)
+		FROM rate_limit_buckets
+		WHERE bucket_key = $1
+		FOR UPDATE
+	`
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&tokens, &elapsed); err != nil {
+		return Decision{}, err
+	}
+
+	tokens, decision := take(tokens, seconds(elapsed), limit)
+
+	sqlStatement = `
+		UPDATE rate_limit_buckets
+		SET tokens = $2, updated_at = now(), full_at = now() + make_interval(secs => $3)
+		WHERE bucket_key = $1
+	`
+	if _, err := tx.ExecContext(ctx, sqlStatement, key, tokens, decision.Reset.Seconds()); err != nil {
+		return Decision{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Decision{}, err
+	}
+	return decision, nil
+}
+
+// Prune deletes buckets that have refilled completely. They hold nothing a
+// new bucket would not, so they are recreated on demand.
+func (p *Postgres) Prune(ctx context.Context) (int64, error) {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE full_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PruneEvery deletes full buckets every interval until ctx is cancelled.
+func (p *Postgres) PruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := p.Prune(ctx); err != nil {
+			slog.Error("pruning rate limit buckets", "err", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+)
+
+// Limiter decides whether the client identified by key may make another
+// request under limit, taking a token from its bucket if so.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit config.RateLimit) (Decision, error)
+}
+
+// Decision is the outcome of a request against a bucket, with what is needed
+// for the RateLimit-* and Retry-After headers.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// take refills a bucket holding tokens that was last updated elapsed ago and
+// takes one token from it if it can. It returns the tokens left.
+func take(tokens float64, elapsed time.Duration, limit config.RateLimit) (float64, Decision) {
+	perSecond := limit.RequestsPerMinute / 60
+	burst := float64(limit.Burst)
+
+	if elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed.Seconds()*perSecond)
+	}
+
+	decision := Decision{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = seconds((burst - tokens) / perSecond)
+	return tokens, decision
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
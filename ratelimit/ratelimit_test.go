@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/mocks"
+)
+
+func TestMemoryAllow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.Now = func() time.Time { return now }
+	limit := config.RateLimit{RequestsPerMinute: 60, Burst: 3}
+
+	t.Run("Burst Then Reject", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			decision, _ := m.Allow(ctx, "client", limit)
+			if !decision.Allowed || decision.Remaining != 2-i {
+				t.Fatalf("Expected request %d allowed with %d remaining; got %+v", i+1, 2-i, decision)
+			}
+		}
+
+		decision, _ := m.Allow(ctx, "client", limit)
+		if decision.Allowed {
+			t.Fatal("Expected the fourth request to be rejected")
+		}
+		if decision.RetryAfter != time.Second {
+			t.Errorf("Expected retry after 1s; got %s", decision.RetryAfter)
+		}
+		if decision.Reset != 3*time.Second {
+			t.Errorf("Expected the bucket to be full again in 3s; got %s", decision.Reset)
+		}
+	})
+
+	t.Run("Other Clients Unaffected", func(t *testing.T) {
+		decision, _ := m.Allow(ctx, "other", limit)
+		if !decision.Allowed {
+			t.Error("Expected another client to be allowed")
+		}
+	})
+
+	t.Run("Refills Over Time", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+
+		decision, _ := m.Allow(ctx, "client", limit)
+		if !decision.Allowed || decision.Remaining != 0 {
+			t.Errorf("Expected one refilled request allowed; got %+v", decision)
+		}
+		if decision, _ := m.Allow(ctx, "client", limit); decision.Allowed {
+			t.Error("Expected the half-refilled bucket to reject the next request")
+		}
+
+		now = now.Add(time.Hour)
+		decision, _ = m.Allow(ctx, "client", limit)
+		if !decision.Allowed || decision.Remaining != 2 {
+			t.Errorf("Expected a full bucket after an hour; got %+v", decision)
+		}
+	})
+}
+
+func TestPostgresAllow(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	p := NewPostgres(db)
+	limit := config.RateLimit{RequestsPerMinute: 60, Burst: 5}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO rate_limit_buckets`).WithArgs("client", 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM rate_limit_buckets\s+WHERE bucket_key = \$1\s+FOR UPDATE`).WithArgs("client").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "elapsed"}).AddRow(0.25, 0.5))
+	mock.ExpectExec(`UPDATE rate_limit_buckets\s+SET tokens = \$2, updated_at = now\(\), full_at = now\(\) \+ make_interval\(secs => \$3\)`).
+		WithArgs("client", 0.75, 4.25).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	decision, err := p.Allow(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Allowed {
+		t.Error("Expected a bucket with 0.75 tokens to reject the request")
+	}
+	if decision.RetryAfter != 250*time.Millisecond {
+		t.Errorf("Expected retry after 250ms; got %s", decision.RetryAfter)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresPrune(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	p := NewPostgres(db)
+
+	mock.ExpectExec(`DELETE FROM rate_limit_buckets WHERE full_at < now\(\)`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	pruned, err := p.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 3 {
+		t.Errorf("Expected 3 buckets to be pruned; got %d", pruned)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
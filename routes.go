@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leeshuoan/gds-OneCV/auth"
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/handlers"
+	"github.com/leeshuoan/gds-OneCV/metrics"
+	"github.com/leeshuoan/gds-OneCV/middleware"
+	"github.com/leeshuoan/gds-OneCV/ratelimit"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// newHandler builds the router with every route and wraps it in the
+// middleware shared by all requests. Notifications sent through it are
+// queued for delivery on channels.
+func newHandler(cfg config.Config, s store.Store, conn *sql.DB, m *metrics.Metrics, limiter ratelimit.Limiter, channels []string, logger *slog.Logger) http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", handlers.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		handlers.Readyz(w, r, conn, time.Duration(cfg.Server.ReadinessTimeout))
+	}).Methods("GET")
+	router.Handle("/metrics", m.Handler()).Methods("GET")
+
+	api := router.PathPrefix("/api").Subrouter()
+	if cfg.RateLimit.Enabled {
+		// Limiting by IP first throttles requests with missing or invalid
+		// keys before Authenticate looks them up in the database.
+		api.Use(middleware.RateLimitByIP(limiter, cfg.RateLimit.PerIP))
+	}
+	api.Use(middleware.Authenticate(s))
+	if cfg.RateLimit.Enabled {
+		api.Use(middleware.RateLimit(limiter, cfg.RateLimit))
+	}
+	api.Handle("/register", middleware.Require(auth.PermissionRegistrationsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.Register(w, r, s)
+	})).Methods("POST")
+	api.Handle("/deregister", middleware.Require(auth.PermissionRegistrationsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.Deregister(w, r, s)
+	})).Methods("POST")
+	api.Handle("/commonstudents", middleware.Require(auth.PermissionRegistrationsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.CommonStudents(w, r, s)
+	})).Methods("GET")
+	api.Handle("/suspend", middleware.Require(auth.PermissionSuspensionsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.Suspend(w, r, s)
+	})).Methods("POST")
+	api.Handle("/unsuspend", middleware.Require(auth.PermissionSuspensionsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.Unsuspend(w, r, s)
+	})).Methods("POST")
+	api.Handle("/suspensions", middleware.Require(auth.PermissionSuspensionsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.SuspensionHistory(w, r, s)
+	})).Methods("GET")
+	api.Handle("/retrievefornotifications", middleware.Require(auth.PermissionNotificationsSend, func(w http.ResponseWriter, r *http.Request) {
+		handlers.RetrieveForNotifications(w, r, s, channels)
+	})).Methods("POST")
+	api.Handle("/notifications", middleware.Require(auth.PermissionNotificationsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListNotifications(w, r, s)
+	})).Methods("GET")
+	api.Handle("/notifications/{id}", middleware.Require(auth.PermissionNotificationsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetNotification(w, r, s)
+	})).Methods("GET")
+	api.Handle("/webhooks", middleware.Require(auth.PermissionWebhooksWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateWebhook(w, r, s)
+	})).Methods("POST")
+	api.Handle("/webhooks", middleware.Require(auth.PermissionWebhooksRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListWebhooks(w, r, s)
+	})).Methods("GET")
+	api.Handle("/webhooks/{id}", middleware.Require(auth.PermissionWebhooksRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetWebhook(w, r, s)
+	})).Methods("GET")
+	api.Handle("/webhooks/{id}", middleware.Require(auth.PermissionWebhooksWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteWebhook(w, r, s)
+	})).Methods("DELETE")
+	api.Handle("/webhooks/{id}/deliveries", middleware.Require(auth.PermissionWebhooksRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListWebhookDeliveries(w, r, s)
+	})).Methods("GET")
+
+	api.Handle("/teachers", middleware.Require(auth.PermissionTeachersWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateTeacher(w, r, s)
+	})).Methods("POST")
+	api.Handle("/teachers", middleware.Require(auth.PermissionTeachersRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListTeachers(w, r, s)
+	})).Methods("GET")
+	api.Handle("/teachers/{email}", middleware.Require(auth.PermissionTeachersRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetTeacher(w, r, s)
+	})).Methods("GET")
+	api.Handle("/teachers/{email}", middleware.Require(auth.PermissionTeachersWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateTeacher(w, r, s)
+	})).Methods("PUT")
+	api.Handle("/teachers/{email}", middleware.Require(auth.PermissionTeachersWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteTeacher(w, r, s)
+	})).Methods("DELETE")
+	api.Handle("/students", middleware.Require(auth.PermissionStudentsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateStudent(w, r, s)
+	})).Methods("POST")
+	api.Handle("/students/bulk", middleware.Require(auth.PermissionStudentsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateStudents(w, r, s)
+	})).Methods("POST")
+	api.Handle("/students", middleware.Require(auth.PermissionStudentsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListStudents(w, r, s)
+	})).Methods("GET")
+	api.Handle("/students/{email}", middleware.Require(auth.PermissionStudentsRead, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetStudent(w, r, s)
+	})).Methods("GET")
+	api.Handle("/students/{email}", middleware.Require(auth.PermissionStudentsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateStudent(w, r, s)
+	})).Methods("PUT")
+	api.Handle("/students/{email}", middleware.Require(auth.PermissionStudentsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteStudent(w, r, s)
+	})).Methods("DELETE")
+
+	return middleware.RequestID(middleware.Route(router, middleware.Logging(logger, middleware.Metrics(m, router))))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+	"github.com/leeshuoan/gds-OneCV/metrics"
+	"github.com/leeshuoan/gds-OneCV/ratelimit"
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// countingStore counts the API key lookups made by Authenticate.
+type countingStore struct {
+	*store.Memory
+	lookups int
+}
+
+func (s *countingStore) LookupAPIKey(ctx context.Context, tokenHash string) (store.APIKey, error) {
+	s.lookups++
+	return s.Memory.LookupAPIKey(ctx, tokenHash)
+}
+
+func TestUnauthenticatedRequestsAreRateLimited(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.PerIP = config.RateLimit{RequestsPerMinute: 60, Burst: 3}
+	s := &countingStore{Memory: store.NewMemory()}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	handler := newHandler(cfg, s, nil, metrics.New(), ratelimit.NewMemory(), nil, logger)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/students", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer not-a-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 3; i++ {
+		if rr := send("10.0.0.1:1000"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status %d; got %d", http.StatusUnauthorized, rr.Code)
+		}
+	}
+	rr := send("10.0.0.1:2000")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status %d with Retry-After; got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if s.lookups != 3 {
+		t.Errorf("Expected the limited request not to look up its key; got %d lookups", s.lookups)
+	}
+
+	if rr := send("10.0.0.2:1000"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected another IP to reach authentication; got %d", rr.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+)
+
+// Server runs the HTTP server together with its background workers and
+// shuts both down cleanly when its context is cancelled.
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	workers         []func(ctx context.Context)
+}
+
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:         cfg.ListenAddr,
+			Handler:      handler,
+			ReadTimeout:  time.Duration(cfg.ReadTimeout),
+			WriteTimeout: time.Duration(cfg.WriteTimeout),
+			IdleTimeout:  time.Duration(cfg.IdleTimeout),
+		},
+		shutdownTimeout: time.Duration(cfg.ShutdownTimeout),
+	}
+}
+
+// Go registers a background worker. Workers start with the server and must
+// return once their context is cancelled.
+func (s *Server) Go(worker func(ctx context.Context)) {
+	s.workers = append(s.workers, worker)
+}
+
+// Run listens on the configured address and serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is cancelled. It then stops accepting
+// connections, waits up to the shutdown timeout for in-flight requests to
+// finish, and stops the background workers before returning.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	for _, worker := range s.workers {
+		workers.Add(1)
+		go func(worker func(ctx context.Context)) {
+			defer workers.Done()
+			worker(workerCtx)
+		}(worker)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(listener)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", s.shutdownTimeout)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		if shutdownErr := s.http.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = fmt.Errorf("draining requests: %w", shutdownErr)
+			s.http.Close()
+		}
+		if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+			err = serveErr
+		}
+	}
+
+	stopWorkers()
+	workers.Wait()
+	return err
+}
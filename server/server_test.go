@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/config"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+func TestServe(t *testing.T) {
+	t.Run("In-Flight Request Completes", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			io.WriteString(w, "done")
+		})
+
+		srv := New(config.ServerConfig{ShutdownTimeout: config.Duration(5 * time.Second)}, handler)
+		workerStopped := make(chan struct{})
+		srv.Go(func(ctx context.Context) {
+			<-ctx.Done()
+			close(workerStopped)
+		})
+
+		listener := listen(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- srv.Serve(ctx, listener) }()
+
+		type result struct {
+			status int
+			body   string
+			err    error
+		}
+		responses := make(chan result, 1)
+		go func() {
+			resp, err := http.Get("http://" + listener.Addr().String())
+			if err != nil {
+				responses <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			responses <- result{resp.StatusCode, string(body), err}
+		}()
+
+		<-started
+		cancel()
+
+		select {
+		case err := <-served:
+			t.Fatalf("Expected Serve to wait for the in-flight request; returned %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+
+		res := <-responses
+		if res.err != nil {
+			t.Fatalf("Expected the in-flight request to complete; got %v", res.err)
+		}
+		if res.status != http.StatusOK || res.body != "done" {
+			t.Errorf("Expected status %d with body %q; got %d with %q", http.StatusOK, "done", res.status, res.body)
+		}
+
+		if err := <-served; err != nil {
+			t.Errorf("Expected clean shutdown; got %v", err)
+		}
+		select {
+		case <-workerStopped:
+		default:
+			t.Error("Expected background worker to be stopped")
+		}
+	})
+
+	t.Run("Drain Timeout Exceeded", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+
+		srv := New(config.ServerConfig{ShutdownTimeout: config.Duration(50 * time.Millisecond)}, handler)
+
+		listener := listen(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- srv.Serve(ctx, listener) }()
+
+		go http.Get("http://" + listener.Addr().String())
+		<-started
+		cancel()
+
+		select {
+		case err := <-served:
+			if err == nil {
+				t.Error("Expected an error when requests outlive the shutdown timeout")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Serve to give up after the shutdown timeout")
+		}
+	})
+}
@@ -0,0 +1,925 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a Store backed by maps. It mirrors the constraints of the
+// Postgres schema and is safe for concurrent use.
+type Memory struct {
+	mu            sync.RWMutex
+	teachers      map[string]bool
+	students      map[string]*Student
+	registrations map[string]map[string]bool
+	suspensions   []*Suspension
+	lastID        int64
+	apiKeys       map[string]*APIKey
+	lastKeyID     int64
+	notifications []*Notification
+	deliveries    []*memoryDelivery
+	webhooks      map[int64]*Webhook
+	lastWebhookID int64
+	// webhookDeliveries is in ID order; deleting a webhook removes its
+	// deliveries.
+	webhookDeliveries     []*memoryWebhookDelivery
+	lastWebhookDeliveryID int64
+
+	// RolePermissions are the permissions granted to each role. NewMemory
+	// fills it with the grants made by the roles migration.
+	RolePermissions map[string][]string
+
+	// Now is the clock used for timestamps. Tests may replace it.
+	Now func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		teachers:      make(map[string]bool),
+		students:      make(map[string]*Student),
+		registrations: make(map[string]map[string]bool),
+		apiKeys:       make(map[string]*APIKey),
+		webhooks:      make(map[int64]*Webhook),
+		Now:           time.Now,
+
+		RolePermissions: map[string][]string{
+			"admin": {
+				"notifications:read", "notifications:send", "registrations:read", "registrations:write", "students:read", "students:write",
+				"suspensions:read", "suspensions:write", "teachers:read", "teachers:write", "webhooks:read", "webhooks:write",
+			},
+			"auditor": {"notifications:read", "registrations:read", "students:read", "suspensions:read", "teachers:read", "webhooks:read"},
+			"teacher": {
+				"notifications:read", "notifications:send", "registrations:read", "registrations:write", "students:read",
+				"suspensions:read", "teachers:read",
+			},
+		},
+	}
+}
+
+func (m *Memory) CreateTeacher(ctx context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.teachers[email] {
+		return alreadyExists(EntityTeacher, email)
+	}
+	m.teachers[email] = true
+	m.registrations[email] = make(map[string]bool)
+	return nil
+}
+
+func (m *Memory) TeacherExists(ctx context.Context, email string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.teachers[email], nil
+}
+
+func (m *Memory) GetTeacher(ctx context.Context, email string) (Teacher, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.teachers[email] {
+		return Teacher{}, notFound(EntityTeacher, email)
+	}
+
+	teacher := Teacher{Email: email, Students: []string{}}
+	for student := range m.registrations[email] {
+		teacher.Students = append(teacher.Students, student)
+	}
+	sort.Strings(teacher.Students)
+	return teacher, nil
+}
+
+func (m *Memory) ListTeachers(ctx context.Context, limit, offset int) ([]string, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	teachers := make([]string, 0, len(m.teachers))
+	for teacher := range m.teachers {
+		teachers = append(teachers, teacher)
+	}
+	sort.Strings(teachers)
+	return page(teachers, limit, offset), len(teachers), nil
+}
+
+func (m *Memory) UpdateTeacher(ctx context.Context, email, newEmail string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.teachers[email] {
+		return notFound(EntityTeacher, email)
+	}
+	if email == newEmail {
+		return nil
+	}
+	if m.teachers[newEmail] {
+		return alreadyExists(EntityTeacher, newEmail)
+	}
+
+	m.teachers[newEmail] = true
+	m.registrations[newEmail] = m.registrations[email]
+	delete(m.teachers, email)
+	delete(m.registrations, email)
+	for _, key := range m.apiKeys {
+		if key.Teacher == email {
+			key.Teacher = newEmail
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteTeacher(ctx context.Context, email string, removeRegistrations bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.teachers[email] {
+		return notFound(EntityTeacher, email)
+	}
+	if len(m.registrations[email]) > 0 && !removeRegistrations {
+		return inUse(EntityTeacher, email)
+	}
+
+	delete(m.teachers, email)
+	delete(m.registrations, email)
+	for hash, key := range m.apiKeys {
+		if key.Teacher == email {
+			delete(m.apiKeys, hash)
+		}
+	}
+	return nil
+}
+
+// page returns the window of items selected by limit and offset.
+func page(items []string, limit, offset int) []string {
+	if offset >= len(items) {
+		return []string{}
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (m *Memory) CreateStudent(ctx context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.students[email]; ok {
+		return alreadyExists(EntityStudent, email)
+	}
+	m.students[email] = &Student{Email: email}
+	return nil
+}
+
+func (m *Memory) GetStudent(ctx context.Context, email string) (Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	student, ok := m.students[email]
+	if !ok {
+		return Student{}, notFound(EntityStudent, email)
+	}
+	return m.view(student), nil
+}
+
+func (m *Memory) CreateStudents(ctx context.Context, emails []string) ([]string, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created, existing := []string{}, []string{}
+	for _, email := range distinct(emails) {
+		if _, ok := m.students[email]; ok {
+			existing = append(existing, email)
+			continue
+		}
+		m.students[email] = &Student{Email: email}
+		created = append(created, email)
+	}
+	return created, existing, nil
+}
+
+func (m *Memory) ListStudents(ctx context.Context, filter StudentFilter, limit, offset int) ([]Student, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var emails []string
+	for email, student := range m.students {
+		if filter.Suspended != nil && m.isSuspended(student) != *filter.Suspended {
+			continue
+		}
+		if filter.Teacher != "" && !m.registrations[filter.Teacher][email] {
+			continue
+		}
+		if filter.Domain != "" && !strings.EqualFold(emailDomain(email), filter.Domain) {
+			continue
+		}
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	students := []Student{}
+	for _, email := range page(emails, limit, offset) {
+		students = append(students, m.view(m.students[email]))
+	}
+	return students, len(emails), nil
+}
+
+func (m *Memory) UpdateStudent(ctx context.Context, email, newEmail string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	student, ok := m.students[email]
+	if !ok {
+		return notFound(EntityStudent, email)
+	}
+	if email == newEmail {
+		return nil
+	}
+	if _, ok := m.students[newEmail]; ok {
+		return alreadyExists(EntityStudent, newEmail)
+	}
+
+	student.Email = newEmail
+	m.students[newEmail] = student
+	delete(m.students, email)
+	for _, students := range m.registrations {
+		if students[email] {
+			students[newEmail] = true
+			delete(students, email)
+		}
+	}
+	for _, suspension := range m.suspensions {
+		if suspension.Student == email {
+			suspension.Student = newEmail
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteStudent(ctx context.Context, email string, removeRegistrations bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.students[email]; !ok {
+		return notFound(EntityStudent, email)
+	}
+	if !removeRegistrations {
+		for _, students := range m.registrations {
+			if students[email] {
+				return inUse(EntityStudent, email)
+			}
+		}
+	}
+
+	delete(m.students, email)
+	for _, students := range m.registrations {
+		delete(students, email)
+	}
+	kept := m.suspensions[:0]
+	for _, suspension := range m.suspensions {
+		if suspension.Student != email {
+			kept = append(kept, suspension)
+		}
+	}
+	m.suspensions = kept
+	return nil
+}
+
+// view is the student as callers see it, with a lapsed suspension already
+// treated as over.
+func (m *Memory) view(s *Student) Student {
+	student := *s
+	if !m.isSuspended(s) {
+		student.Suspended = false
+		student.SuspendedUntil = nil
+	}
+	return student
+}
+
+func emailDomain(email string) string {
+	return email[strings.LastIndex(email, "@")+1:]
+}
+
+func (m *Memory) RegisterStudents(ctx context.Context, teacher string, students []string, partial bool) (RegistrationResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.teachers[teacher] {
+		return RegistrationResult{}, notFound(EntityTeacher, teacher)
+	}
+
+	known := make(map[string]bool)
+	for _, student := range students {
+		_, known[student] = m.students[student]
+	}
+
+	result, err := classifyRegistration(students, known, m.registrations[teacher])
+	if err != nil && !partial {
+		return RegistrationResult{}, err
+	}
+
+	for _, student := range result.Registered {
+		m.registrations[teacher][student] = true
+	}
+	return result, nil
+}
+
+func (m *Memory) CommonStudents(ctx context.Context, query CommonStudentsQuery) ([]CommonStudent, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	teachers := distinct(query.Teachers)
+	sort.Strings(teachers)
+	minTeachers := query.MinTeachers
+	if minTeachers == 0 {
+		minTeachers = len(teachers)
+	}
+
+	registeredTo := make(map[string][]string)
+	for _, teacher := range teachers {
+		for student := range m.registrations[teacher] {
+			registeredTo[student] = append(registeredTo[student], teacher)
+		}
+	}
+
+	var common []string
+	for student, registered := range registeredTo {
+		if len(registered) >= minTeachers {
+			common = append(common, student)
+		}
+	}
+	sort.Strings(common)
+
+	total := 0
+	if query.CountTotal {
+		total = len(common)
+	}
+
+	var students []CommonStudent
+	for _, email := range common {
+		if email <= query.After {
+			continue
+		}
+		if query.Limit > 0 && len(students) == query.Limit {
+			break
+		}
+		students = append(students, CommonStudent{
+			Email:     email,
+			Teachers:  registeredTo[email],
+			Suspended: m.isSuspended(m.students[email]),
+		})
+	}
+	return students, total, nil
+}
+
+func (m *Memory) DeregisterStudents(ctx context.Context, teacher string, students []string) (DeregistrationResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.teachers[teacher] {
+		return DeregistrationResult{}, notFound(EntityTeacher, teacher)
+	}
+
+	removed := make(map[string]bool)
+	for _, student := range students {
+		if m.registrations[teacher][student] {
+			delete(m.registrations[teacher], student)
+			removed[student] = true
+		}
+	}
+	return classifyDeregistration(students, removed), nil
+}
+
+func (m *Memory) DeregisterAll(ctx context.Context, teacher string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.teachers[teacher] {
+		return nil, notFound(EntityTeacher, teacher)
+	}
+
+	students := []string{}
+	for student := range m.registrations[teacher] {
+		students = append(students, student)
+	}
+	sort.Strings(students)
+	m.registrations[teacher] = make(map[string]bool)
+	return students, nil
+}
+
+func (m *Memory) SuspendStudent(ctx context.Context, params SuspendParams) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.students[params.Student]
+	if !ok {
+		return false, notFound(EntityStudent, params.Student)
+	}
+	if m.isSuspended(s) {
+		return true, nil
+	}
+	m.expire(s)
+
+	s.Suspended = true
+	s.SuspendedUntil = params.Until
+	m.lastID++
+	m.suspensions = append(m.suspensions, &Suspension{
+		ID:          m.lastID,
+		Student:     params.Student,
+		SuspendedBy: params.SuspendedBy,
+		Reason:      params.Reason,
+		StartedAt:   m.Now(),
+		EndsAt:      params.Until,
+	})
+	return false, nil
+}
+
+func (m *Memory) UnsuspendStudent(ctx context.Context, student string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.students[student]
+	if !ok {
+		return false, notFound(EntityStudent, student)
+	}
+	if !m.isSuspended(s) {
+		m.expire(s)
+		return false, nil
+	}
+
+	m.endSuspension(s, m.Now(), EndReasonUnsuspended)
+	return true, nil
+}
+
+func (m *Memory) SuspensionHistory(ctx context.Context, student string) ([]Suspension, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.students[student]; !ok {
+		return nil, notFound(EntityStudent, student)
+	}
+
+	history := []Suspension{}
+	for _, suspension := range m.suspensions {
+		if suspension.Student == student {
+			history = append(history, *suspension)
+		}
+	}
+	return history, nil
+}
+
+func (m *Memory) ExpireSuspensions(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []string
+	for email, s := range m.students {
+		if m.expire(s) {
+			expired = append(expired, email)
+		}
+	}
+	sort.Strings(expired)
+	return expired, nil
+}
+
+func (m *Memory) isSuspended(s *Student) bool {
+	return s.Suspended && (s.SuspendedUntil == nil || s.SuspendedUntil.After(m.Now()))
+}
+
+// expire ends the student's suspension if its scheduled end has passed.
+func (m *Memory) expire(s *Student) bool {
+	if !s.Suspended || m.isSuspended(s) {
+		return false
+	}
+	m.endSuspension(s, *s.SuspendedUntil, EndReasonExpired)
+	return true
+}
+
+func (m *Memory) endSuspension(s *Student, endedAt time.Time, reason string) {
+	s.Suspended = false
+	s.SuspendedUntil = nil
+	for _, suspension := range m.suspensions {
+		if suspension.Student == s.Email && suspension.EndedAt == nil {
+			suspension.EndedAt = &endedAt
+			suspension.EndReason = reason
+		}
+	}
+}
+
+func (m *Memory) resolveRecipients(teacher string, mentioned []string) []string {
+	seen := make(map[string]bool)
+	var recipients []string
+	add := func(email string) {
+		s, ok := m.students[email]
+		if !ok || m.isSuspended(s) || seen[email] {
+			return
+		}
+		seen[email] = true
+		recipients = append(recipients, email)
+	}
+
+	for student := range m.registrations[teacher] {
+		add(student)
+	}
+	for _, student := range mentioned {
+		add(student)
+	}
+	sort.Strings(recipients)
+	return recipients
+}
+
+func (m *Memory) CreateNotification(ctx context.Context, teacher, message string, mentioned []string, channels []string) (Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recipients := m.resolveRecipients(teacher, mentioned)
+	notification := &Notification{
+		ID:             int64(len(m.notifications) + 1),
+		Teacher:        teacher,
+		Message:        message,
+		CreatedAt:      m.Now(),
+		RecipientCount: len(recipients),
+		Recipients:     recipients,
+	}
+	m.notifications = append(m.notifications, notification)
+
+	for _, recipient := range recipients {
+		for _, channel := range distinct(channels) {
+			m.deliveries = append(m.deliveries, &memoryDelivery{
+				Delivery: Delivery{
+					ID:             int64(len(m.deliveries) + 1),
+					NotificationID: notification.ID,
+					Channel:        channel,
+					Recipient:      recipient,
+					Teacher:        teacher,
+					Message:        message,
+					Status:         DeliveryPending,
+				},
+				nextAttemptAt: notification.CreatedAt,
+			})
+		}
+	}
+
+	for _, webhook := range m.sortedWebhooks() {
+		if contains(webhook.EventTypes, EventNotificationSent) {
+			m.lastWebhookDeliveryID++
+			m.webhookDeliveries = append(m.webhookDeliveries, &memoryWebhookDelivery{
+				WebhookDelivery: WebhookDelivery{
+					ID:             m.lastWebhookDeliveryID,
+					WebhookID:      webhook.ID,
+					EventType:      EventNotificationSent,
+					NotificationID: notification.ID,
+					Status:         DeliveryPending,
+					CreatedAt:      notification.CreatedAt,
+				},
+				nextAttemptAt: notification.CreatedAt,
+			})
+		}
+	}
+	return copyNotification(notification), nil
+}
+
+func (m *Memory) GetNotification(ctx context.Context, id int64) (Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id < 1 || id > int64(len(m.notifications)) {
+		return Notification{}, notFound(EntityNotification, strconv.FormatInt(id, 10))
+	}
+	return copyNotification(m.notifications[id-1]), nil
+}
+
+func (m *Memory) ListNotifications(ctx context.Context, filter NotificationFilter, limit, offset int) ([]Notification, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matches []Notification
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		n := m.notifications[i]
+		switch {
+		case filter.Teacher != "" && n.Teacher != filter.Teacher:
+			continue
+		case filter.Student != "" && !contains(n.Recipients, filter.Student):
+			continue
+		case !filter.From.IsZero() && n.CreatedAt.Before(filter.From):
+			continue
+		case !filter.To.IsZero() && !n.CreatedAt.Before(filter.To):
+			continue
+		}
+		listed := copyNotification(n)
+		listed.Recipients = nil
+		matches = append(matches, listed)
+	}
+
+	notifications := []Notification{}
+	if offset < len(matches) {
+		notifications = matches[offset:min(offset+limit, len(matches))]
+	}
+	return notifications, len(matches), nil
+}
+
+func copyNotification(n *Notification) Notification {
+	notification := *n
+	notification.Recipients = append([]string{}, n.Recipients...)
+	return notification
+}
+
+func contains(emails []string, email string) bool {
+	for _, e := range emails {
+		if e == email {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryDelivery struct {
+	Delivery
+	nextAttemptAt time.Time
+}
+
+func (m *Memory) ClaimDeliveries(ctx context.Context, channels []string, limit int, lease time.Duration) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	var due []*memoryDelivery
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryPending && !d.nextAttemptAt.After(now) && contains(channels, d.Channel) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].nextAttemptAt.Before(due[j].nextAttemptAt) })
+
+	claimed := []Delivery{}
+	for _, d := range due[:min(limit, len(due))] {
+		d.Attempts++
+		d.nextAttemptAt = now.Add(lease)
+		claimed = append(claimed, d.Delivery)
+	}
+	return claimed, nil
+}
+
+func (m *Memory) CompleteDelivery(ctx context.Context, id int64) error {
+	return m.updateDelivery(id, func(d *memoryDelivery) {
+		deliveredAt := m.Now()
+		d.Status = DeliveryDelivered
+		d.DeliveredAt = &deliveredAt
+		d.LastError = ""
+	})
+}
+
+func (m *Memory) RetryDelivery(ctx context.Context, id int64, lastError string, retryAt time.Time) error {
+	return m.updateDelivery(id, func(d *memoryDelivery) {
+		d.LastError = lastError
+		d.nextAttemptAt = retryAt
+	})
+}
+
+func (m *Memory) FailDelivery(ctx context.Context, id int64, lastError string) error {
+	return m.updateDelivery(id, func(d *memoryDelivery) {
+		d.Status = DeliveryFailed
+		d.LastError = lastError
+	})
+}
+
+func (m *Memory) updateDelivery(id int64, update func(d *memoryDelivery)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > int64(len(m.deliveries)) {
+		return notFound(EntityDelivery, strconv.FormatInt(id, 10))
+	}
+	update(m.deliveries[id-1])
+	return nil
+}
+
+func (m *Memory) ListDeliveries(ctx context.Context, notificationID int64) ([]Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := []Delivery{}
+	for _, d := range m.deliveries {
+		if d.NotificationID == notificationID {
+			deliveries = append(deliveries, d.Delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].Recipient != deliveries[j].Recipient {
+			return deliveries[i].Recipient < deliveries[j].Recipient
+		}
+		return deliveries[i].Channel < deliveries[j].Channel
+	})
+	return deliveries, nil
+}
+
+func (m *Memory) CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastWebhookID++
+	webhook.ID = m.lastWebhookID
+	webhook.EventTypes = append([]string{}, webhook.EventTypes...)
+	webhook.CreatedAt = m.Now()
+	m.webhooks[webhook.ID] = &webhook
+	return copyWebhook(&webhook), nil
+}
+
+func (m *Memory) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, notFound(EntityWebhook, strconv.FormatInt(id, 10))
+	}
+	return copyWebhook(webhook), nil
+}
+
+func (m *Memory) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhooks := []Webhook{}
+	for _, webhook := range m.sortedWebhooks() {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	return webhooks, nil
+}
+
+func (m *Memory) sortedWebhooks() []*Webhook {
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+func copyWebhook(w *Webhook) Webhook {
+	webhook := *w
+	webhook.EventTypes = append([]string{}, w.EventTypes...)
+	return webhook
+}
+
+func (m *Memory) DeleteWebhook(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return notFound(EntityWebhook, strconv.FormatInt(id, 10))
+	}
+	delete(m.webhooks, id)
+
+	remaining := m.webhookDeliveries[:0]
+	for _, d := range m.webhookDeliveries {
+		if d.WebhookID != id {
+			remaining = append(remaining, d)
+		}
+	}
+	m.webhookDeliveries = remaining
+	return nil
+}
+
+type memoryWebhookDelivery struct {
+	WebhookDelivery
+	nextAttemptAt time.Time
+}
+
+func (m *Memory) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	var due []*memoryWebhookDelivery
+	for _, d := range m.webhookDeliveries {
+		if d.Status == DeliveryPending && !d.nextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].nextAttemptAt.Before(due[j].nextAttemptAt) })
+
+	claimed := []WebhookDelivery{}
+	for _, d := range due[:min(limit, len(due))] {
+		d.Attempts++
+		d.nextAttemptAt = now.Add(lease)
+		claimed = append(claimed, d.WebhookDelivery)
+	}
+	return claimed, nil
+}
+
+func (m *Memory) CompleteWebhookDelivery(ctx context.Context, id int64, responseStatus int) error {
+	return m.updateWebhookDelivery(id, func(d *memoryWebhookDelivery) {
+		deliveredAt := m.Now()
+		d.Status = DeliveryDelivered
+		d.ResponseStatus = responseStatus
+		d.LastError = ""
+		d.DeliveredAt = &deliveredAt
+	})
+}
+
+func (m *Memory) RetryWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string, retryAt time.Time) error {
+	return m.updateWebhookDelivery(id, func(d *memoryWebhookDelivery) {
+		d.ResponseStatus = responseStatus
+		d.LastError = lastError
+		d.nextAttemptAt = retryAt
+	})
+}
+
+func (m *Memory) FailWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string) error {
+	return m.updateWebhookDelivery(id, func(d *memoryWebhookDelivery) {
+		d.Status = DeliveryFailed
+		d.ResponseStatus = responseStatus
+		d.LastError = lastError
+	})
+}
+
+func (m *Memory) updateWebhookDelivery(id int64, update func(d *memoryWebhookDelivery)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.webhookDeliveries {
+		if d.ID == id {
+			update(d)
+			return nil
+		}
+	}
+	return notFound(EntityDelivery, strconv.FormatInt(id, 10))
+}
+
+func (m *Memory) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.webhooks[webhookID]; !ok {
+		return nil, 0, notFound(EntityWebhook, strconv.FormatInt(webhookID, 10))
+	}
+	var matches []WebhookDelivery
+	for i := len(m.webhookDeliveries) - 1; i >= 0; i-- {
+		if d := m.webhookDeliveries[i]; d.WebhookID == webhookID {
+			matches = append(matches, d.WebhookDelivery)
+		}
+	}
+
+	deliveries := []WebhookDelivery{}
+	if offset < len(matches) {
+		deliveries = matches[offset:min(offset+limit, len(matches))]
+	}
+	return deliveries, len(matches), nil
+}
+
+func (m *Memory) CreateAPIKey(ctx context.Context, key APIKey, tokenHash string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.RolePermissions[key.Role]; !ok {
+		return APIKey{}, notFound(EntityRole, key.Role)
+	}
+	if key.Teacher != "" && !m.teachers[key.Teacher] {
+		return APIKey{}, notFound(EntityTeacher, key.Teacher)
+	}
+	if _, ok := m.apiKeys[tokenHash]; ok {
+		return APIKey{}, alreadyExists(EntityAPIKey, "")
+	}
+
+	m.lastKeyID++
+	key.ID = m.lastKeyID
+	key.CreatedAt = m.Now()
+	key.RevokedAt = nil
+	key.Permissions = nil
+	m.apiKeys[tokenHash] = &key
+	return key, nil
+}
+
+func (m *Memory) LookupAPIKey(ctx context.Context, tokenHash string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[tokenHash]
+	if !ok || key.RevokedAt != nil {
+		return APIKey{}, notFound(EntityAPIKey, "")
+	}
+	found := *key
+	found.Permissions = append([]string{}, m.RolePermissions[key.Role]...)
+	return found, nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.apiKeys {
+		if key.ID == id && key.RevokedAt == nil {
+			now := m.Now()
+			key.RevokedAt = &now
+			return nil
+		}
+	}
+	return notFound(EntityAPIKey, strconv.FormatInt(id, 10))
+}
@@ -0,0 +1,1192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// suspendedCondition holds for students whose suspension is in effect. A
+// time-boxed suspension stops counting as soon as it passes, before
+// ExpireSuspensions records its end.
+const suspendedCondition = `(is_suspended AND (suspended_until IS NULL OR suspended_until > now()))`
+
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) CreateTeacher(ctx context.Context, email string) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO teachers (teacher_email) VALUES ($1)`, email)
+	if isUniqueViolation(err) {
+		return alreadyExists(EntityTeacher, email)
+	}
+	return err
+}
+
+func (p *Postgres) TeacherExists(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM teachers WHERE teacher_email = $1)`, email).Scan(&exists)
+	return exists, err
+}
+
+func (p *Postgres) GetTeacher(ctx context.Context, email string) (Teacher, error) {
+	exists, err := p.TeacherExists(ctx, email)
+	if err != nil {
+		return Teacher{}, err
+	}
+	if !exists {
+		return Teacher{}, notFound(EntityTeacher, email)
+	}
+
+	query := `SELECT student_email FROM registrations WHERE teacher_email = $1 ORDER BY student_email`
+	rows, err := p.db.QueryContext(ctx, query, email)
+	if err != nil {
+		return Teacher{}, err
+	}
+	students, err := scanEmails(rows)
+	if err != nil {
+		return Teacher{}, err
+	}
+	if students == nil {
+		students = []string{}
+	}
+	return Teacher{Email: email, Students: students}, nil
+}
+
+func (p *Postgres) ListTeachers(ctx context.Context, limit, offset int) ([]string, int, error) {
+	var total int
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM teachers`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT teacher_email FROM teachers ORDER BY teacher_email LIMIT $1 OFFSET $2`
+	rows, err := p.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	teachers, err := scanEmails(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	if teachers == nil {
+		teachers = []string{}
+	}
+	return teachers, total, nil
+}
+
+func (p *Postgres) UpdateTeacher(ctx context.Context, email, newEmail string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTeacher(ctx, tx, email); err != nil {
+		return err
+	}
+	if email == newEmail {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO teachers (teacher_email) VALUES ($1)`, newEmail); err != nil {
+		if isUniqueViolation(err) {
+			return alreadyExists(EntityTeacher, newEmail)
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE registrations SET teacher_email = $2 WHERE teacher_email = $1`, email, newEmail); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE api_keys SET teacher_email = $2 WHERE teacher_email = $1`, email, newEmail); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM teachers WHERE teacher_email = $1`, email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteTeacher(ctx context.Context, email string, removeRegistrations bool) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTeacher(ctx, tx, email); err != nil {
+		return err
+	}
+
+	if !removeRegistrations {
+		var registered bool
+		query := `SELECT EXISTS (SELECT 1 FROM registrations WHERE teacher_email = $1)`
+		if err := tx.QueryRowContext(ctx, query, email).Scan(&registered); err != nil {
+			return err
+		}
+		if registered {
+			return inUse(EntityTeacher, email)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM registrations WHERE teacher_email = $1`, email); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM teachers WHERE teacher_email = $1`, email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockTeacher locks the teacher's row for the rest of the transaction.
+func lockTeacher(ctx context.Context, tx *sql.Tx, email string) error {
+	var found string
+	err := tx.QueryRowContext(ctx, `SELECT teacher_email FROM teachers WHERE teacher_email = $1 FOR UPDATE`, email).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(EntityTeacher, email)
+	}
+	return err
+}
+
+func (p *Postgres) CreateStudent(ctx context.Context, email string) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO students (student_email) VALUES ($1)`, email)
+	if isUniqueViolation(err) {
+		return alreadyExists(EntityStudent, email)
+	}
+	return err
+}
+
+func (p *Postgres) GetStudent(ctx context.Context, email string) (Student, error) {
+	student := Student{Email: email}
+	var suspendedUntil sql.NullTime
+	query := `SELECT ` + suspendedCondition + `, suspended_until FROM students WHERE student_email = $1`
+	err := p.db.QueryRowContext(ctx, query, email).Scan(&student.Suspended, &suspendedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Student{}, notFound(EntityStudent, email)
+	}
+	if student.Suspended && suspendedUntil.Valid {
+		student.SuspendedUntil = &suspendedUntil.Time
+	}
+	return student, err
+}
+
+func (p *Postgres) CreateStudents(ctx context.Context, emails []string) ([]string, []string, error) {
+	query := `
+		INSERT INTO students (student_email)
+		SELECT DISTINCT unnest($1::text[])
+		ON CONFLICT (student_email) DO NOTHING
+		RETURNING student_email
+	`
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(emails))
+	if err != nil {
+		return nil, nil, err
+	}
+	inserted, err := scanEmails(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isInserted := make(map[string]bool, len(inserted))
+	for _, email := range inserted {
+		isInserted[email] = true
+	}
+	created, existing := []string{}, []string{}
+	for _, email := range distinct(emails) {
+		if isInserted[email] {
+			created = append(created, email)
+		} else {
+			existing = append(existing, email)
+		}
+	}
+	return created, existing, nil
+}
+
+func (p *Postgres) ListStudents(ctx context.Context, filter StudentFilter, limit, offset int) ([]Student, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			conditions = append(conditions, suspendedCondition)
+		} else {
+			conditions = append(conditions, "NOT "+suspendedCondition)
+		}
+	}
+	if filter.Teacher != "" {
+		args = append(args, filter.Teacher)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM registrations r WHERE r.student_email = students.student_email AND r.teacher_email = $%d)", len(args)))
+	}
+	if filter.Domain != "" {
+		args = append(args, filter.Domain)
+		conditions = append(conditions, fmt.Sprintf("lower(split_part(student_email, '@', 2)) = lower($%d)", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM students `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT student_email, %s, suspended_until
+		FROM students
+		%s
+		ORDER BY student_email
+		LIMIT $%d OFFSET $%d
+	`, suspendedCondition, where, len(args)+1, len(args)+2)
+	rows, err := p.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	students := []Student{}
+	for rows.Next() {
+		var student Student
+		var suspendedUntil sql.NullTime
+		if err := rows.Scan(&student.Email, &student.Suspended, &suspendedUntil); err != nil {
+			return nil, 0, err
+		}
+		if student.Suspended && suspendedUntil.Valid {
+			student.SuspendedUntil = &suspendedUntil.Time
+		}
+		students = append(students, student)
+	}
+	return students, total, rows.Err()
+}
+
+func (p *Postgres) UpdateStudent(ctx context.Context, email, newEmail string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockStudent(ctx, tx, email); err != nil {
+		return err
+	}
+	if email == newEmail {
+		return nil
+	}
+
+	sqlStatement := `
+		INSERT INTO students (student_email, is_suspended, suspended_until)
+		SELECT $2, is_suspended, suspended_until FROM students WHERE student_email = $1
+	`
+	if _, err := tx.ExecContext(ctx, sqlStatement, email, newEmail); err != nil {
+		if isUniqueViolation(err) {
+			return alreadyExists(EntityStudent, newEmail)
+		}
+		return err
+	}
+	for _, sqlStatement := range []string{
+		`UPDATE registrations SET student_email = $2 WHERE student_email = $1`,
+		`UPDATE suspensions SET student_email = $2 WHERE student_email = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, sqlStatement, email, newEmail); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM students WHERE student_email = $1`, email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteStudent(ctx context.Context, email string, removeRegistrations bool) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockStudent(ctx, tx, email); err != nil {
+		return err
+	}
+
+	if !removeRegistrations {
+		var registered bool
+		query := `SELECT EXISTS (SELECT 1 FROM registrations WHERE student_email = $1)`
+		if err := tx.QueryRowContext(ctx, query, email).Scan(&registered); err != nil {
+			return err
+		}
+		if registered {
+			return inUse(EntityStudent, email)
+		}
+	}
+
+	for _, sqlStatement := range []string{
+		`DELETE FROM registrations WHERE student_email = $1`,
+		`DELETE FROM suspensions WHERE student_email = $1`,
+		`DELETE FROM students WHERE student_email = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, sqlStatement, email); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) RegisterStudents(ctx context.Context, teacher string, students []string, partial bool) (RegistrationResult, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RegistrationResult{}, err
+	}
+	defer tx.Rollback()
+
+	var teacherExists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM teachers WHERE teacher_email = $1)`, teacher).Scan(&teacherExists)
+	if err != nil {
+		return RegistrationResult{}, err
+	}
+	if !teacherExists {
+		return RegistrationResult{}, notFound(EntityTeacher, teacher)
+	}
+
+	query := `
+		SELECT r.email, s.student_email IS NOT NULL, reg.student_email IS NOT NULL
+		FROM unnest($2::text[]) AS r(email)
+		LEFT JOIN students s ON s.student_email = r.email
+		LEFT JOIN registrations reg ON reg.student_email = r.email AND reg.teacher_email = $1
+	`
+	rows, err := tx.QueryContext(ctx, query, teacher, pq.Array(students))
+	if err != nil {
+		return RegistrationResult{}, err
+	}
+	known := make(map[string]bool)
+	registered := make(map[string]bool)
+	for rows.Next() {
+		var email string
+		var isKnown, isRegistered bool
+		if err := rows.Scan(&email, &isKnown, &isRegistered); err != nil {
+			rows.Close()
+			return RegistrationResult{}, err
+		}
+		known[email] = isKnown
+		registered[email] = isRegistered
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return RegistrationResult{}, err
+	}
+
+	result, err := classifyRegistration(students, known, registered)
+	if err != nil && !partial {
+		return RegistrationResult{}, err
+	}
+
+	if len(result.Registered) > 0 {
+		inserted, err := insertRegistrations(ctx, tx, teacher, result.Registered)
+		if err != nil {
+			tx.Rollback()
+			return RegistrationResult{}, p.registrationError(ctx, err, teacher, result.Registered)
+		}
+
+		// A concurrent request may have registered some students between the
+		// lookup and the insert.
+		candidates := result.Registered
+		result.Registered = []string{}
+		for _, student := range candidates {
+			if inserted[student] {
+				result.Registered = append(result.Registered, student)
+				continue
+			}
+			if !partial {
+				return RegistrationResult{}, alreadyExists(EntityRegistration, student)
+			}
+			result.AlreadyRegistered = append(result.AlreadyRegistered, student)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RegistrationResult{}, err
+	}
+	return result, nil
+}
+
+func insertRegistrations(ctx context.Context, tx *sql.Tx, teacher string, students []string) (map[string]bool, error) {
+	values := make([]string, len(students))
+	args := make([]interface{}, 0, len(students)+1)
+	args = append(args, teacher)
+	for i, student := range students {
+		values[i] = fmt.Sprintf("($1, $%d)", i+2)
+		args = append(args, student)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO registrations (teacher_email, student_email)
+		VALUES %s
+		ON CONFLICT (teacher_email, student_email) DO NOTHING
+		RETURNING student_email
+	`, strings.Join(values, ", "))
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	emails, err := scanEmails(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		inserted[email] = true
+	}
+	return inserted, nil
+}
+
+func (p *Postgres) CommonStudents(ctx context.Context, query CommonStudentsQuery) ([]CommonStudent, int, error) {
+	teachers := distinct(query.Teachers)
+	minTeachers := query.MinTeachers
+	if minTeachers == 0 {
+		minTeachers = len(teachers)
+	}
+	common := `
+		SELECT student_email, array_agg(teacher_email ORDER BY teacher_email) AS teachers
+		FROM registrations
+		WHERE teacher_email = ANY($1)
+		GROUP BY student_email
+		HAVING COUNT(DISTINCT teacher_email) >= $2
+	`
+
+	var total int
+	if query.CountTotal {
+		err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (`+common+`) common`, pq.Array(teachers), minTeachers).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var limit interface{}
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+	page := fmt.Sprintf(`
+		SELECT common.student_email, common.teachers, %s
+		FROM (%s) common
+		JOIN students ON students.student_email = common.student_email
+		WHERE common.student_email > $3
+		ORDER BY common.student_email
+		LIMIT $4
+	`, suspendedCondition, common)
+	rows, err := p.db.QueryContext(ctx, page, pq.Array(teachers), minTeachers, query.After, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var students []CommonStudent
+	for rows.Next() {
+		var student CommonStudent
+		if err := rows.Scan(&student.Email, pq.Array(&student.Teachers), &student.Suspended); err != nil {
+			return nil, 0, err
+		}
+		students = append(students, student)
+	}
+	return students, total, rows.Err()
+}
+
+func (p *Postgres) DeregisterStudents(ctx context.Context, teacher string, students []string) (DeregistrationResult, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return DeregistrationResult{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockTeacher(ctx, tx, teacher); err != nil {
+		return DeregistrationResult{}, err
+	}
+
+	sqlStatement := `DELETE FROM registrations WHERE teacher_email = $1 AND student_email = ANY($2) RETURNING student_email`
+	rows, err := tx.QueryContext(ctx, sqlStatement, teacher, pq.Array(students))
+	if err != nil {
+		return DeregistrationResult{}, err
+	}
+	deleted, err := scanEmails(rows)
+	if err != nil {
+		return DeregistrationResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DeregistrationResult{}, err
+	}
+
+	removed := make(map[string]bool, len(deleted))
+	for _, student := range deleted {
+		removed[student] = true
+	}
+	return classifyDeregistration(students, removed), nil
+}
+
+func (p *Postgres) DeregisterAll(ctx context.Context, teacher string) ([]string, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockTeacher(ctx, tx, teacher); err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM registrations WHERE teacher_email = $1 RETURNING student_email
+		)
+		SELECT student_email FROM deleted ORDER BY student_email
+	`
+	rows, err := tx.QueryContext(ctx, query, teacher)
+	if err != nil {
+		return nil, err
+	}
+	students, err := scanEmails(rows)
+	if err != nil {
+		return nil, err
+	}
+	if students == nil {
+		students = []string{}
+	}
+
+	return students, tx.Commit()
+}
+
+func (p *Postgres) SuspendStudent(ctx context.Context, params SuspendParams) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	suspended, err := lockStudent(ctx, tx, params.Student)
+	if err != nil {
+		return false, err
+	}
+	if suspended {
+		return true, nil
+	}
+	if _, err := expireSuspensions(ctx, tx, params.Student); err != nil {
+		return false, err
+	}
+
+	sqlStatement := `UPDATE students SET is_suspended = true, suspended_until = $2 WHERE student_email = $1`
+	result, err := tx.ExecContext(ctx, sqlStatement, params.Student, params.Until)
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if rowsAffected == 0 {
+		return false, notFound(EntityStudent, params.Student)
+	}
+
+	sqlStatement = `INSERT INTO suspensions (student_email, suspended_by, reason, ends_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, sqlStatement, params.Student, params.SuspendedBy, params.Reason, params.Until); err != nil {
+		return false, err
+	}
+
+	return false, tx.Commit()
+}
+
+func (p *Postgres) UnsuspendStudent(ctx context.Context, student string) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	suspended, err := lockStudent(ctx, tx, student)
+	if err != nil {
+		return false, err
+	}
+	if !suspended {
+		if _, err := expireSuspensions(ctx, tx, student); err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	}
+
+	sqlStatement := `UPDATE students SET is_suspended = false, suspended_until = NULL WHERE student_email = $1`
+	if _, err := tx.ExecContext(ctx, sqlStatement, student); err != nil {
+		return false, err
+	}
+
+	sqlStatement = `UPDATE suspensions SET ended_at = now(), end_reason = $2 WHERE student_email = $1 AND ended_at IS NULL`
+	if _, err := tx.ExecContext(ctx, sqlStatement, student, EndReasonUnsuspended); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (p *Postgres) SuspensionHistory(ctx context.Context, student string) ([]Suspension, error) {
+	if _, err := p.GetStudent(ctx, student); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT suspension_id, student_email, suspended_by, reason, started_at, ends_at, ended_at, end_reason
+		FROM suspensions
+		WHERE student_email = $1
+		ORDER BY started_at, suspension_id
+	`
+	rows, err := p.db.QueryContext(ctx, query, student)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []Suspension{}
+	for rows.Next() {
+		var suspension Suspension
+		var endsAt, endedAt sql.NullTime
+		err := rows.Scan(&suspension.ID, &suspension.Student, &suspension.SuspendedBy, &suspension.Reason,
+			&suspension.StartedAt, &endsAt, &endedAt, &suspension.EndReason)
+		if err != nil {
+			return nil, err
+		}
+		if endsAt.Valid {
+			suspension.EndsAt = &endsAt.Time
+		}
+		if endedAt.Valid {
+			suspension.EndedAt = &endedAt.Time
+		}
+		history = append(history, suspension)
+	}
+	return history, rows.Err()
+}
+
+func (p *Postgres) ExpireSuspensions(ctx context.Context) ([]string, error) {
+	return expireSuspensions(ctx, p.db, "")
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// expireSuspensions ends the suspensions that have passed their scheduled
+// end, limited to one student unless student is empty.
+func expireSuspensions(ctx context.Context, q queryer, student string) ([]string, error) {
+	query := `
+		WITH expired AS (
+			UPDATE students
+			SET is_suspended = false, suspended_until = NULL
+			WHERE is_suspended AND suspended_until <= now() AND ($1::text = '' OR student_email = $1)
+			RETURNING student_email
+		), ended AS (
+			UPDATE suspensions s
+			SET ended_at = s.ends_at, end_reason = $2
+			FROM expired e
+			WHERE s.student_email = e.student_email AND s.ended_at IS NULL
+		)
+		SELECT student_email FROM expired ORDER BY student_email
+	`
+	rows, err := q.QueryContext(ctx, query, student, EndReasonExpired)
+	if err != nil {
+		return nil, err
+	}
+	return scanEmails(rows)
+}
+
+// lockStudent locks the student's row for the rest of the transaction and
+// reports whether the student is currently suspended.
+func lockStudent(ctx context.Context, tx *sql.Tx, student string) (bool, error) {
+	var suspended bool
+	query := `SELECT ` + suspendedCondition + ` FROM students WHERE student_email = $1 FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, student).Scan(&suspended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, notFound(EntityStudent, student)
+	}
+	return suspended, err
+}
+
+// recipientsQuery selects the recipients of a notification from teacher ($1)
+// mentioning the students in $2.
+const recipientsQuery = `
+	SELECT r.student_email
+	FROM registrations r, students s
+	WHERE r.student_email = s.student_email AND teacher_email = $1 AND NOT ` + suspendedCondition + `
+	UNION
+	SELECT student_email
+	FROM students
+	WHERE student_email = ANY($2) AND NOT ` + suspendedCondition + `
+	ORDER BY student_email
+`
+
+func (p *Postgres) CreateNotification(ctx context.Context, teacher, message string, mentioned []string, channels []string) (Notification, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Notification{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, recipientsQuery, teacher, pq.Array(mentioned))
+	if err != nil {
+		return Notification{}, err
+	}
+	recipients, err := scanEmails(rows)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	notification := Notification{Teacher: teacher, Message: message, RecipientCount: len(recipients), Recipients: recipients}
+	sqlStatement := `INSERT INTO notifications (teacher_email, message) VALUES ($1, $2) RETURNING notification_id, created_at`
+	if err := tx.QueryRowContext(ctx, sqlStatement, teacher, message).Scan(&notification.ID, &notification.CreatedAt); err != nil {
+		return Notification{}, err
+	}
+
+	sqlStatement = `
+		INSERT INTO notification_recipients (notification_id, student_email)
+		SELECT $1, unnest($2::text[])
+	`
+	if _, err := tx.ExecContext(ctx, sqlStatement, notification.ID, pq.Array(recipients)); err != nil {
+		return Notification{}, err
+	}
+
+	if len(channels) > 0 && len(recipients) > 0 {
+		sqlStatement = `
+			INSERT INTO notification_deliveries (notification_id, channel, recipient)
+			SELECT $1, channel, recipient
+			FROM unnest($2::text[]) AS recipient CROSS JOIN (SELECT DISTINCT unnest($3::text[]) AS channel) AS channels
+		`
+		if _, err := tx.ExecContext(ctx, sqlStatement, notification.ID, pq.Array(recipients), pq.Array(channels)); err != nil {
+			return Notification{}, err
+		}
+	}
+
+	sqlStatement = `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, notification_id, created_at, next_attempt_at)
+		SELECT webhook_id, $2, $1, $3, $3 FROM webhooks WHERE $2 = ANY(event_types)
+	`
+	if _, err := tx.ExecContext(ctx, sqlStatement, notification.ID, EventNotificationSent, notification.CreatedAt); err != nil {
+		return Notification{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Notification{}, err
+	}
+	if notification.Recipients == nil {
+		notification.Recipients = []string{}
+	}
+	return notification, nil
+}
+
+func (p *Postgres) GetNotification(ctx context.Context, id int64) (Notification, error) {
+	notification := Notification{ID: id}
+	query := `SELECT teacher_email, message, created_at FROM notifications WHERE notification_id = $1`
+	err := p.db.QueryRowContext(ctx, query, id).Scan(&notification.Teacher, &notification.Message, &notification.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Notification{}, notFound(EntityNotification, strconv.FormatInt(id, 10))
+	}
+	if err != nil {
+		return Notification{}, err
+	}
+
+	query = `SELECT student_email FROM notification_recipients WHERE notification_id = $1 ORDER BY student_email`
+	rows, err := p.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return Notification{}, err
+	}
+	if notification.Recipients, err = scanEmails(rows); err != nil {
+		return Notification{}, err
+	}
+	if notification.Recipients == nil {
+		notification.Recipients = []string{}
+	}
+	notification.RecipientCount = len(notification.Recipients)
+	return notification, nil
+}
+
+func (p *Postgres) ListNotifications(ctx context.Context, filter NotificationFilter, limit, offset int) ([]Notification, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Teacher != "" {
+		args = append(args, filter.Teacher)
+		conditions = append(conditions, fmt.Sprintf("teacher_email = $%d", len(args)))
+	}
+	if filter.Student != "" {
+		args = append(args, filter.Student)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM notification_recipients r WHERE r.notification_id = notifications.notification_id AND r.student_email = $%d)", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT notification_id, teacher_email, message, created_at,
+			(SELECT COUNT(*) FROM notification_recipients r WHERE r.notification_id = notifications.notification_id)
+		FROM notifications
+		%s
+		ORDER BY created_at DESC, notification_id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := p.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Teacher, &n.Message, &n.CreatedAt, &n.RecipientCount); err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, total, rows.Err()
+}
+
+func scanEmails(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+// registrationError reports a teacher or student deleted between the lookup
+// and the insert of their registrations as not found, naming the first of
+// students that is missing.
+func (p *Postgres) registrationError(ctx context.Context, err error, teacher string, students []string) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return err
+	}
+	switch pqErr.Constraint {
+	case "registrations_teacher_email_fkey":
+		return notFound(EntityTeacher, teacher)
+	case "registrations_student_email_fkey":
+		query := `
+			SELECT r.email
+			FROM unnest($1::text[]) WITH ORDINALITY AS r(email, position)
+			WHERE NOT EXISTS (SELECT 1 FROM students WHERE student_email = r.email)
+			ORDER BY r.position
+			LIMIT 1
+		`
+		var missing string
+		if lookupErr := p.db.QueryRowContext(ctx, query, pq.Array(students)).Scan(&missing); lookupErr != nil {
+			return err
+		}
+		return notFound(EntityStudent, missing)
+	}
+	return err
+}
+
+func (p *Postgres) ClaimDeliveries(ctx context.Context, channels []string, limit int, lease time.Duration) ([]Delivery, error) {
+	query := `
+		UPDATE notification_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $3)
+		FROM notifications n
+		WHERE n.notification_id = d.notification_id AND d.delivery_id IN (
+			SELECT delivery_id FROM notification_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now() AND channel = ANY($1)
+			ORDER BY next_attempt_at, delivery_id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.delivery_id, d.notification_id, d.channel, d.recipient, n.teacher_email, n.message, d.status, d.attempts, COALESCE(d.last_error, '')
+	`
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(channels), limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.NotificationID, &d.Channel, &d.Recipient, &d.Teacher, &d.Message, &d.Status, &d.Attempts, &d.LastError); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) CompleteDelivery(ctx context.Context, id int64) error {
+	return p.updateDelivery(ctx, id, `UPDATE notification_deliveries SET status = 'delivered', delivered_at = now(), last_error = NULL WHERE delivery_id = $1`)
+}
+
+func (p *Postgres) RetryDelivery(ctx context.Context, id int64, lastError string, retryAt time.Time) error {
+	return p.updateDelivery(ctx, id, `UPDATE notification_deliveries SET last_error = $2, next_attempt_at = $3 WHERE delivery_id = $1`, lastError, retryAt)
+}
+
+func (p *Postgres) FailDelivery(ctx context.Context, id int64, lastError string) error {
+	return p.updateDelivery(ctx, id, `UPDATE notification_deliveries SET status = 'failed', last_error = $2 WHERE delivery_id = $1`, lastError)
+}
+
+func (p *Postgres) updateDelivery(ctx context.Context, id int64, sqlStatement string, args ...interface{}) error {
+	result, err := p.db.ExecContext(ctx, sqlStatement, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFound(EntityDelivery, strconv.FormatInt(id, 10))
+	}
+	return nil
+}
+
+func (p *Postgres) ListDeliveries(ctx context.Context, notificationID int64) ([]Delivery, error) {
+	query := `
+		SELECT d.delivery_id, d.channel, d.recipient, n.teacher_email, n.message, d.status, d.attempts, COALESCE(d.last_error, ''), d.delivered_at
+		FROM notification_deliveries d
+		JOIN notifications n ON n.notification_id = d.notification_id
+		WHERE d.notification_id = $1
+		ORDER BY d.recipient, d.channel
+	`
+	rows, err := p.db.QueryContext(ctx, query, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d := Delivery{NotificationID: notificationID}
+		if err := rows.Scan(&d.ID, &d.Channel, &d.Recipient, &d.Teacher, &d.Message, &d.Status, &d.Attempts, &d.LastError, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error) {
+	sqlStatement := `INSERT INTO webhooks (url, secret, event_types) VALUES ($1, $2, $3) RETURNING webhook_id, created_at`
+	err := p.db.QueryRowContext(ctx, sqlStatement, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes)).
+		Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (p *Postgres) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	webhook := Webhook{ID: id}
+	query := `SELECT url, secret, event_types, created_at FROM webhooks WHERE webhook_id = $1`
+	err := p.db.QueryRowContext(ctx, query, id).Scan(&webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Webhook{}, notFound(EntityWebhook, strconv.FormatInt(id, 10))
+	}
+	if err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (p *Postgres) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT webhook_id, url, secret, event_types, created_at FROM webhooks ORDER BY webhook_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (p *Postgres) DeleteWebhook(ctx context.Context, id int64) error {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM webhooks WHERE webhook_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFound(EntityWebhook, strconv.FormatInt(id, 10))
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = `delivery_id, webhook_id, event_type, notification_id, status, attempts,
+	COALESCE(response_status, 0), COALESCE(last_error, ''), created_at, delivered_at`
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.NotificationID, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+		WHERE delivery_id IN (
+			SELECT delivery_id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, delivery_id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+	rows, err := p.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func (p *Postgres) CompleteWebhookDelivery(ctx context.Context, id int64, responseStatus int) error {
+	return p.updateDelivery(ctx, id, `
+		UPDATE webhook_deliveries SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = now()
+		WHERE delivery_id = $1`, responseStatus)
+}
+
+func (p *Postgres) RetryWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string, retryAt time.Time) error {
+	return p.updateDelivery(ctx, id, `
+		UPDATE webhook_deliveries SET response_status = NULLIF($2, 0), last_error = $3, next_attempt_at = $4
+		WHERE delivery_id = $1`, responseStatus, lastError, retryAt)
+}
+
+func (p *Postgres) FailWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string) error {
+	return p.updateDelivery(ctx, id, `
+		UPDATE webhook_deliveries SET status = 'failed', response_status = NULLIF($2, 0), last_error = $3
+		WHERE delivery_id = $1`, responseStatus, lastError)
+}
+
+func (p *Postgres) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, int, error) {
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE webhook_id = $1)`, webhookID).Scan(&exists); err != nil {
+		return nil, 0, err
+	}
+	if !exists {
+		return nil, 0, notFound(EntityWebhook, strconv.FormatInt(webhookID, 10))
+	}
+
+	var total int
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`, webhookID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, delivery_id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := p.db.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func (p *Postgres) CreateAPIKey(ctx context.Context, key APIKey, tokenHash string) (APIKey, error) {
+	query := `
+		INSERT INTO api_keys (token_hash, name, role, teacher_email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING key_id, created_at
+	`
+	err := p.db.QueryRowContext(ctx, query, tokenHash, key.Name, key.Role, key.Teacher).Scan(&key.ID, &key.CreatedAt)
+	if isForeignKeyViolation(err) {
+		if err.(*pq.Error).Constraint == "api_keys_role_fkey" {
+			return APIKey{}, notFound(EntityRole, key.Role)
+		}
+		return APIKey{}, notFound(EntityTeacher, key.Teacher)
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (p *Postgres) LookupAPIKey(ctx context.Context, tokenHash string) (APIKey, error) {
+	query := `
+		SELECT k.key_id, k.name, k.role, COALESCE(k.teacher_email, ''), k.created_at,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM api_keys k
+		LEFT JOIN role_permissions rp ON rp.role = k.role
+		WHERE k.token_hash = $1 AND k.revoked_at IS NULL
+		GROUP BY k.key_id
+	`
+	var key APIKey
+	err := p.db.QueryRowContext(ctx, query, tokenHash).
+		Scan(&key.ID, &key.Name, &key.Role, &key.Teacher, &key.CreatedAt, pq.Array(&key.Permissions))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, notFound(EntityAPIKey, "")
+	}
+	return key, err
+}
+
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id int64) error {
+	result, err := p.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = now() WHERE key_id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFound(EntityAPIKey, strconv.FormatInt(id, 10))
+	}
+	return nil
+}
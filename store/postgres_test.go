@@ -0,0 +1,582 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leeshuoan/gds-OneCV/mocks"
+	"github.com/lib/pq"
+)
+
+func TestPostgresRegisterStudents(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	expectLookup := func(rows *sqlmock.Rows) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs("teacher@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`FROM unnest`).WillReturnRows(rows)
+	}
+	lookupRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"email", "known", "registered"})
+	}
+
+	t.Run("Successful Registration", func(t *testing.T) {
+		expectLookup(lookupRows().
+			AddRow("studentjon@example.com", true, false).
+			AddRow("studenthon@example.com", true, false))
+		mock.ExpectQuery(`INSERT INTO registrations`).
+			WithArgs("teacher@example.com", "studentjon@example.com", "studenthon@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
+				AddRow("studentjon@example.com").
+				AddRow("studenthon@example.com"))
+		mock.ExpectCommit()
+
+		result, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"studentjon@example.com", "studenthon@example.com"}, false)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		expected := []string{"studentjon@example.com", "studenthon@example.com"}
+		if !reflect.DeepEqual(result.Registered, expected) {
+			t.Errorf("Expected %v registered; got %v", expected, result.Registered)
+		}
+	})
+
+	t.Run("Non-Existent Teacher", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs("teacher@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		_, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"student@example.com"}, false)
+		assertStoreError(t, err, EntityTeacher, "teacher@example.com", ErrNotFound)
+	})
+
+	t.Run("Duplicate Student Registration", func(t *testing.T) {
+		expectLookup(lookupRows().
+			AddRow("studentjon@example.com", true, false).
+			AddRow("student@example.com", true, true))
+		mock.ExpectRollback()
+
+		_, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"studentjon@example.com", "student@example.com"}, false)
+		assertStoreError(t, err, EntityRegistration, "student@example.com", ErrAlreadyExists)
+	})
+
+	t.Run("Non-Existent Student", func(t *testing.T) {
+		expectLookup(lookupRows().AddRow("student@example.com", false, false))
+		mock.ExpectRollback()
+
+		_, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"student@example.com"}, false)
+		assertStoreError(t, err, EntityStudent, "student@example.com", ErrNotFound)
+	})
+
+	t.Run("Concurrent Registration", func(t *testing.T) {
+		expectLookup(lookupRows().AddRow("student@example.com", true, false))
+		mock.ExpectQuery(`INSERT INTO registrations`).
+			WillReturnRows(sqlmock.NewRows([]string{"student_email"}))
+		mock.ExpectRollback()
+
+		_, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"student@example.com"}, false)
+		assertStoreError(t, err, EntityRegistration, "student@example.com", ErrAlreadyExists)
+	})
+
+	t.Run("Student Deleted Before Insert", func(t *testing.T) {
+		expectLookup(lookupRows().
+			AddRow("studentjon@example.com", true, false).
+			AddRow("student@example.com", true, false))
+		mock.ExpectQuery(`INSERT INTO registrations`).
+			WillReturnError(&pq.Error{Code: "23503", Constraint: "registrations_student_email_fkey"})
+		mock.ExpectRollback()
+		mock.ExpectQuery(`SELECT r.email\s+FROM unnest`).
+			WithArgs(pq.Array([]string{"studentjon@example.com", "student@example.com"})).
+			WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("student@example.com"))
+
+		_, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"studentjon@example.com", "student@example.com"}, false)
+		assertStoreError(t, err, EntityStudent, "student@example.com", ErrNotFound)
+	})
+
+	t.Run("Partial Registration", func(t *testing.T) {
+		expectLookup(lookupRows().
+			AddRow("studentjon@example.com", true, false).
+			AddRow("student@example.com", true, true).
+			AddRow("unknown@example.com", false, false))
+		mock.ExpectQuery(`INSERT INTO registrations`).
+			WithArgs("teacher@example.com", "studentjon@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"student_email"}).AddRow("studentjon@example.com"))
+		mock.ExpectCommit()
+
+		result, err := s.RegisterStudents(context.Background(), "teacher@example.com", []string{"studentjon@example.com", "student@example.com", "unknown@example.com"}, true)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		expected := RegistrationResult{
+			Registered:        []string{"studentjon@example.com"},
+			AlreadyRegistered: []string{"student@example.com"},
+			UnknownStudents:   []string{"unknown@example.com"},
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v; got %+v", expected, result)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func assertStoreError(t *testing.T, err error, entity, email string, target error) {
+	t.Helper()
+
+	var storeErr *Error
+	if !errors.As(err, &storeErr) || !errors.Is(err, target) {
+		t.Fatalf("Expected %v store error; got %v", target, err)
+	}
+	if storeErr.Entity != entity || storeErr.Email != email {
+		t.Errorf("Expected %s %s; got %s %s", entity, email, storeErr.Entity, storeErr.Email)
+	}
+}
+
+func TestPostgresDeleteTeacher(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	expectLock := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM teachers WHERE teacher_email = \$1 FOR UPDATE`).WithArgs("teacherken@gmail.com").
+			WillReturnRows(sqlmock.NewRows([]string{"teacher_email"}).AddRow("teacherken@gmail.com"))
+	}
+
+	t.Run("Reject With Registrations", func(t *testing.T) {
+		expectLock()
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs("teacherken@gmail.com").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := s.DeleteTeacher(context.Background(), "teacherken@gmail.com", false)
+		assertStoreError(t, err, EntityTeacher, "teacherken@gmail.com", ErrInUse)
+	})
+
+	t.Run("Remove Registrations", func(t *testing.T) {
+		expectLock()
+		mock.ExpectExec(`DELETE FROM registrations`).WithArgs("teacherken@gmail.com").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(`DELETE FROM teachers`).WithArgs("teacherken@gmail.com").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.DeleteTeacher(context.Background(), "teacherken@gmail.com", true); err != nil {
+			t.Errorf("Expected no error; got %v", err)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresCreateStudents(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	emails := []string{"studentnew@gmail.com", "studentbob@gmail.com", "studentnew@gmail.com", "studentbob@gmail.com"}
+	mock.ExpectQuery(`INSERT INTO students`).
+		WithArgs(pq.Array(emails)).
+		WillReturnRows(sqlmock.NewRows([]string{"student_email"}).AddRow("studentnew@gmail.com"))
+
+	created, existing, err := s.CreateStudents(context.Background(), emails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(created, []string{"studentnew@gmail.com"}) || !reflect.DeepEqual(existing, []string{"studentbob@gmail.com"}) {
+		t.Errorf("Expected each email reported once; got created %v and existing %v", created, existing)
+	}
+}
+
+func TestPostgresListStudents(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	suspended := true
+	filter := StudentFilter{Suspended: &suspended, Teacher: "teacherken@gmail.com", Domain: "gmail.com"}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM students WHERE .* AND EXISTS .*\$1\) AND lower\(split_part\(student_email, '@', 2\)\) = lower\(\$2\)`).
+		WithArgs("teacherken@gmail.com", "gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`LIMIT \$3 OFFSET \$4`).
+		WithArgs("teacherken@gmail.com", "gmail.com", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"student_email", "suspended", "suspended_until"}).
+			AddRow("studentmary@gmail.com", true, nil))
+
+	students, total, err := s.ListStudents(context.Background(), filter, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Student{{Email: "studentmary@gmail.com", Suspended: true}}
+	if total != 3 || !reflect.DeepEqual(students, expected) {
+		t.Errorf("Expected %v of 3; got %v of %d", expected, students, total)
+	}
+}
+
+func TestPostgresCommonStudents(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+	teachers := pq.Array([]string{"teacherken@gmail.com", "teacherjoe@gmail.com"})
+	columns := []string{"student_email", "teachers", "suspended"}
+
+	t.Run("All Students", func(t *testing.T) {
+		mock.ExpectQuery("SELECT common.student_email, common.teachers").
+			WithArgs(teachers, 2, "", nil).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("commonstudent1@gmail.com", "{teacherjoe@gmail.com,teacherken@gmail.com}", false).
+				AddRow("commonstudent2@gmail.com", "{teacherjoe@gmail.com,teacherken@gmail.com}", true))
+
+		query := CommonStudentsQuery{Teachers: []string{"teacherken@gmail.com", "teacherjoe@gmail.com", "teacherken@gmail.com"}}
+		students, _, err := s.CommonStudents(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		both := []string{"teacherjoe@gmail.com", "teacherken@gmail.com"}
+		expected := []CommonStudent{
+			{Email: "commonstudent1@gmail.com", Teachers: both},
+			{Email: "commonstudent2@gmail.com", Teachers: both, Suspended: true},
+		}
+		if !reflect.DeepEqual(students, expected) {
+			t.Errorf("Expected %v; got %v", expected, students)
+		}
+	})
+
+	t.Run("Page of Any With Total", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM`).
+			WithArgs(teachers, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT common.student_email, common.teachers").
+			WithArgs(teachers, 1, "commonstudent2@gmail.com", 1).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("student_only_under_teacher_ken@gmail.com", "{teacherken@gmail.com}", false))
+
+		query := CommonStudentsQuery{
+			Teachers:    []string{"teacherken@gmail.com", "teacherjoe@gmail.com"},
+			MinTeachers: 1,
+			After:       "commonstudent2@gmail.com",
+			Limit:       1,
+			CountTotal:  true,
+		}
+		students, total, err := s.CommonStudents(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []CommonStudent{{Email: "student_only_under_teacher_ken@gmail.com", Teachers: []string{"teacherken@gmail.com"}}}
+		if total != 3 || !reflect.DeepEqual(students, expected) {
+			t.Errorf("Expected %v of 3; got %v of %d", expected, students, total)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresSuspendStudent(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+	until := time.Date(2023, 7, 8, 8, 0, 0, 0, time.UTC)
+
+	t.Run("Successful Suspension", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM students WHERE student_email = \$1 FOR UPDATE`).WithArgs("studentmary@gmail.com").
+			WillReturnRows(sqlmock.NewRows([]string{"is_suspended"}).AddRow(false))
+		mock.ExpectQuery("WITH expired AS").WithArgs("studentmary@gmail.com", EndReasonExpired).
+			WillReturnRows(sqlmock.NewRows([]string{"student_email"}))
+		mock.ExpectExec("UPDATE students SET is_suspended = true").
+			WithArgs("studentmary@gmail.com", until).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO suspensions").
+			WithArgs("studentmary@gmail.com", "teacherken@gmail.com", "Truancy", until).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		alreadySuspended, err := s.SuspendStudent(context.Background(), SuspendParams{Student: "studentmary@gmail.com", SuspendedBy: "teacherken@gmail.com", Reason: "Truancy", Until: &until})
+		if err != nil || alreadySuspended {
+			t.Errorf("Expected new suspension; got %v, %v", alreadySuspended, err)
+		}
+	})
+
+	t.Run("Already Suspended", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM students WHERE student_email = \$1 FOR UPDATE`).WithArgs("studentmary@gmail.com").
+			WillReturnRows(sqlmock.NewRows([]string{"is_suspended"}).AddRow(true))
+		mock.ExpectRollback()
+
+		alreadySuspended, err := s.SuspendStudent(context.Background(), SuspendParams{Student: "studentmary@gmail.com"})
+		if err != nil || !alreadySuspended {
+			t.Errorf("Expected existing suspension; got %v, %v", alreadySuspended, err)
+		}
+	})
+
+	t.Run("Student Not Found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM students WHERE student_email = \$1 FOR UPDATE`).WithArgs("nonexistentstudent@gmail.com").
+			WillReturnRows(sqlmock.NewRows([]string{"is_suspended"}))
+		mock.ExpectRollback()
+
+		_, err := s.SuspendStudent(context.Background(), SuspendParams{Student: "nonexistentstudent@gmail.com"})
+		assertStoreError(t, err, EntityStudent, "nonexistentstudent@gmail.com", ErrNotFound)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresUnsuspendStudent(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM students WHERE student_email = \$1 FOR UPDATE`).WithArgs("studentmary@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"is_suspended"}).AddRow(true))
+	mock.ExpectExec("UPDATE students SET is_suspended = false").
+		WithArgs("studentmary@gmail.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE suspensions SET ended_at").
+		WithArgs("studentmary@gmail.com", EndReasonUnsuspended).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	wasSuspended, err := s.UnsuspendStudent(context.Background(), "studentmary@gmail.com")
+	if err != nil || !wasSuspended {
+		t.Errorf("Expected suspension to end; got %v, %v", wasSuspended, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresExpireSuspensions(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	mock.ExpectQuery("WITH expired AS").WithArgs("", EndReasonExpired).
+		WillReturnRows(sqlmock.NewRows([]string{"student_email"}).AddRow("studentmary@gmail.com"))
+
+	expired, err := s.ExpireSuspensions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expired, []string{"studentmary@gmail.com"}) {
+		t.Errorf("Expected studentmary@gmail.com to expire; got %v", expired)
+	}
+}
+
+func TestPostgresCreateNotification(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+	sent := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT r.student_email`).
+		WithArgs("teacherken@gmail.com", pq.Array([]string{"studentagnes@gmail.com"})).
+		WillReturnRows(sqlmock.NewRows([]string{"student_email"}).
+			AddRow("studentagnes@gmail.com").
+			AddRow("studentbob@gmail.com"))
+	mock.ExpectQuery(`INSERT INTO notifications`).WithArgs("teacherken@gmail.com", "Exam on Monday").
+		WillReturnRows(sqlmock.NewRows([]string{"notification_id", "created_at"}).AddRow(7, sent))
+	mock.ExpectExec(`INSERT INTO notification_recipients`).
+		WithArgs(7, pq.Array([]string{"studentagnes@gmail.com", "studentbob@gmail.com"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO notification_deliveries`).
+		WithArgs(7, pq.Array([]string{"studentagnes@gmail.com", "studentbob@gmail.com"}), pq.Array([]string{"email"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO webhook_deliveries`).WithArgs(7, EventNotificationSent, sent).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	notification, err := s.CreateNotification(context.Background(), "teacherken@gmail.com", "Exam on Monday", []string{"studentagnes@gmail.com"}, []string{"email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Notification{
+		ID:             7,
+		Teacher:        "teacherken@gmail.com",
+		Message:        "Exam on Monday",
+		CreatedAt:      sent,
+		RecipientCount: 2,
+		Recipients:     []string{"studentagnes@gmail.com", "studentbob@gmail.com"},
+	}
+	if !reflect.DeepEqual(notification, expected) {
+		t.Errorf("Expected %+v; got %+v", expected, notification)
+	}
+
+	mock.ExpectQuery(`FROM notifications WHERE notification_id = \$1`).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"teacher_email", "message", "created_at"}))
+	_, err = s.GetNotification(context.Background(), 8)
+	assertStoreError(t, err, EntityNotification, "8", ErrNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresDeliveries(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	mock.ExpectQuery(`UPDATE notification_deliveries d`).WithArgs(pq.Array([]string{"email"}), 1, 60.0).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "notification_id", "channel", "recipient", "teacher_email", "message", "status", "attempts", "last_error"}).
+			AddRow(3, 7, "email", "studentagnes@gmail.com", "teacherken@gmail.com", "Exam on Monday", "pending", 2, "connection refused"))
+
+	deliveries, err := s.ClaimDeliveries(context.Background(), []string{"email"}, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Delivery{{
+		ID:             3,
+		NotificationID: 7,
+		Channel:        "email",
+		Recipient:      "studentagnes@gmail.com",
+		Teacher:        "teacherken@gmail.com",
+		Message:        "Exam on Monday",
+		Status:         DeliveryPending,
+		Attempts:       2,
+		LastError:      "connection refused",
+	}}
+	if !reflect.DeepEqual(deliveries, expected) {
+		t.Errorf("Expected %+v; got %+v", expected, deliveries)
+	}
+
+	mock.ExpectExec(`UPDATE notification_deliveries SET status = 'delivered'`).WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.CompleteDelivery(context.Background(), 3); err != nil {
+		t.Error(err)
+	}
+
+	mock.ExpectExec(`UPDATE notification_deliveries SET status = 'failed'`).WithArgs(4, "550 No such user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.FailDelivery(context.Background(), 4, "550 No such user")
+	assertStoreError(t, err, EntityDelivery, "4", ErrNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresWebhooks(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO webhooks`).
+		WithArgs("https://lms.example.com/hooks", "secret", pq.Array([]string{EventNotificationSent})).
+		WillReturnRows(sqlmock.NewRows([]string{"webhook_id", "created_at"}).AddRow(2, created))
+
+	webhook, err := s.CreateWebhook(context.Background(), Webhook{
+		URL:        "https://lms.example.com/hooks",
+		Secret:     "secret",
+		EventTypes: []string{EventNotificationSent},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhook.ID != 2 || !webhook.CreatedAt.Equal(created) {
+		t.Errorf("Expected webhook 2 created at %s; got %+v", created, webhook)
+	}
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM webhooks`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	_, _, err = s.ListWebhookDeliveries(context.Background(), 3, 50, 0)
+	assertStoreError(t, err, EntityWebhook, "3", ErrNotFound)
+
+	mock.ExpectExec(`UPDATE webhook_deliveries SET response_status = NULLIF\(\$2, 0\)`).
+		WithArgs(5, 0, "connection refused", created).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.RetryWebhookDelivery(context.Background(), 5, 0, "connection refused", created); err != nil {
+		t.Error(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresAPIKeys(t *testing.T) {
+	db, mock := mocks.NewMock()
+	defer db.Close()
+	s := NewPostgres(db)
+
+	t.Run("Create For Unknown Teacher", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO api_keys`).WithArgs("hash", "", "teacher", "unknown@gmail.com").
+			WillReturnError(&pq.Error{Code: "23503"})
+
+		_, err := s.CreateAPIKey(context.Background(), APIKey{Role: "teacher", Teacher: "unknown@gmail.com"}, "hash")
+		assertStoreError(t, err, EntityTeacher, "unknown@gmail.com", ErrNotFound)
+	})
+
+	t.Run("Create With Unknown Role", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO api_keys`).WithArgs("hash", "", "janitor", "").
+			WillReturnError(&pq.Error{Code: "23503", Constraint: "api_keys_role_fkey"})
+
+		_, err := s.CreateAPIKey(context.Background(), APIKey{Role: "janitor"}, "hash")
+		assertStoreError(t, err, EntityRole, "janitor", ErrNotFound)
+	})
+
+	t.Run("Lookup Active Key", func(t *testing.T) {
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(`FROM api_keys k\s+LEFT JOIN role_permissions rp ON rp.role = k.role\s+WHERE k.token_hash = \$1 AND k.revoked_at IS NULL`).
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows([]string{"key_id", "name", "role", "teacher_email", "created_at", "permissions"}).
+				AddRow(7, "laptop", "teacher", "teacherken@gmail.com", created, "{registrations:write,students:read}"))
+
+		key, err := s.LookupAPIKey(context.Background(), "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := APIKey{
+			ID: 7, Name: "laptop", Role: "teacher", Teacher: "teacherken@gmail.com", CreatedAt: created,
+			Permissions: []string{"registrations:write", "students:read"},
+		}
+		if !reflect.DeepEqual(key, expected) {
+			t.Errorf("Expected %+v; got %+v", expected, key)
+		}
+	})
+
+	t.Run("Lookup Unknown Key", func(t *testing.T) {
+		mock.ExpectQuery(`FROM api_keys`).WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"key_id", "name", "role", "teacher_email", "created_at", "permissions"}))
+
+		_, err := s.LookupAPIKey(context.Background(), "missing")
+		assertStoreError(t, err, EntityAPIKey, "", ErrNotFound)
+	})
+
+	t.Run("Revoke Unknown Key", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE api_keys SET revoked_at = now\(\)`).WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := s.RevokeAPIKey(context.Background(), 42)
+		assertStoreError(t, err, EntityAPIKey, "42", ErrNotFound)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
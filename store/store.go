@@ -0,0 +1,431 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrInUse         = errors.New("still in use")
+)
+
+const (
+	EntityTeacher      = "teacher"
+	EntityStudent      = "student"
+	EntityRegistration = "registration"
+	EntityAPIKey       = "api key"
+	EntityRole         = "role"
+	EntityNotification = "notification"
+	EntityDelivery     = "delivery"
+	EntityWebhook      = "webhook"
+)
+
+// Error ties a store failure to the teacher or student it concerns so that
+// handlers can report it without knowing which backend produced it.
+type Error struct {
+	Entity string
+	Email  string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Entity, e.Email, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func notFound(entity, email string) error {
+	return &Error{Entity: entity, Email: email, Err: ErrNotFound}
+}
+
+func alreadyExists(entity, email string) error {
+	return &Error{Entity: entity, Email: email, Err: ErrAlreadyExists}
+}
+
+func inUse(entity, email string) error {
+	return &Error{Entity: entity, Email: email, Err: ErrInUse}
+}
+
+type Teacher struct {
+	Email    string
+	Students []string
+}
+
+const (
+	EndReasonUnsuspended = "unsuspended"
+	EndReasonExpired     = "expired"
+)
+
+// Student reports Suspended only while a suspension is in effect; a
+// suspension whose SuspendedUntil has passed is treated as over even before
+// the sweeper records its expiry.
+type Student struct {
+	Email          string
+	Suspended      bool
+	SuspendedUntil *time.Time
+}
+
+// StudentFilter narrows ListStudents. Zero fields do not filter.
+type StudentFilter struct {
+	Suspended *bool
+	Teacher   string
+	Domain    string
+}
+
+// SuspendParams describes a new suspension. A nil Until suspends the student
+// until they are unsuspended.
+type SuspendParams struct {
+	Student     string
+	SuspendedBy string
+	Reason      string
+	Until       *time.Time
+}
+
+// Suspension is one period during which a student was suspended. EndedAt is
+// nil while the suspension is still active, and EndsAt is the scheduled end
+// of a time-boxed suspension.
+type Suspension struct {
+	ID          int64
+	Student     string
+	SuspendedBy string
+	Reason      string
+	StartedAt   time.Time
+	EndsAt      *time.Time
+	EndedAt     *time.Time
+	EndReason   string
+}
+
+// CommonStudentsQuery selects the students registered to at least
+// MinTeachers of Teachers, or to every one of them when MinTeachers is 0.
+// Students are returned in email order, starting after the email After and
+// at most Limit of them, or all when Limit is 0. The total number of matches
+// is only counted when CountTotal is set.
+type CommonStudentsQuery struct {
+	Teachers    []string
+	MinTeachers int
+	After       string
+	Limit       int
+	CountTotal  bool
+}
+
+// CommonStudent is a student found by CommonStudents together with the
+// queried teachers they are registered to, in email order.
+type CommonStudent struct {
+	Email     string
+	Teachers  []string
+	Suspended bool
+}
+
+// RegistrationResult lists, in request order, what happened to each student
+// passed to RegisterStudents.
+type RegistrationResult struct {
+	Registered        []string
+	AlreadyRegistered []string
+	UnknownStudents   []string
+}
+
+// classifyRegistration sorts the distinct students into the result lists,
+// treating every student that is known and not yet registered as
+// registered. The returned error describes the first student that stops an
+// all-or-nothing registration.
+func classifyRegistration(students []string, known, registered map[string]bool) (RegistrationResult, error) {
+	result := RegistrationResult{
+		Registered:        []string{},
+		AlreadyRegistered: []string{},
+		UnknownStudents:   []string{},
+	}
+
+	var firstErr error
+	seen := make(map[string]bool)
+	for _, student := range students {
+		if seen[student] {
+			continue
+		}
+		seen[student] = true
+
+		switch {
+		case !known[student]:
+			result.UnknownStudents = append(result.UnknownStudents, student)
+			if firstErr == nil {
+				firstErr = notFound(EntityStudent, student)
+			}
+		case registered[student]:
+			result.AlreadyRegistered = append(result.AlreadyRegistered, student)
+			if firstErr == nil {
+				firstErr = alreadyExists(EntityRegistration, student)
+			}
+		default:
+			result.Registered = append(result.Registered, student)
+		}
+	}
+	return result, firstErr
+}
+
+// DeregistrationResult lists, in request order, which students were removed
+// from a teacher and which were not registered to begin with.
+type DeregistrationResult struct {
+	Deregistered  []string
+	NotRegistered []string
+}
+
+// distinct returns emails without duplicates, keeping the first occurrence of
+// each.
+func distinct(emails []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, email := range emails {
+		if !seen[email] {
+			seen[email] = true
+			result = append(result, email)
+		}
+	}
+	return result
+}
+
+func classifyDeregistration(students []string, removed map[string]bool) DeregistrationResult {
+	result := DeregistrationResult{
+		Deregistered:  []string{},
+		NotRegistered: []string{},
+	}
+
+	seen := make(map[string]bool)
+	for _, student := range students {
+		if seen[student] {
+			continue
+		}
+		seen[student] = true
+
+		if removed[student] {
+			result.Deregistered = append(result.Deregistered, student)
+		} else {
+			result.NotRegistered = append(result.NotRegistered, student)
+		}
+	}
+	return result
+}
+
+type TeacherStore interface {
+	CreateTeacher(ctx context.Context, email string) error
+	TeacherExists(ctx context.Context, email string) (bool, error)
+	// GetTeacher returns the teacher along with their registered students.
+	GetTeacher(ctx context.Context, email string) (Teacher, error)
+	// ListTeachers returns a page of teacher emails in order, together with
+	// the total number of teachers.
+	ListTeachers(ctx context.Context, limit, offset int) (teachers []string, total int, err error)
+	// UpdateTeacher changes a teacher's email, carrying their registrations
+	// over to the new address.
+	UpdateTeacher(ctx context.Context, email, newEmail string) error
+	// DeleteTeacher removes a teacher. A teacher with registered students is
+	// only removed, together with the registrations, if removeRegistrations
+	// is set; otherwise ErrInUse is returned.
+	DeleteTeacher(ctx context.Context, email string, removeRegistrations bool) error
+}
+
+type StudentStore interface {
+	CreateStudent(ctx context.Context, email string) error
+	// CreateStudents creates the given students in one transaction, skipping
+	// and reporting the ones that already exist. An email given more than
+	// once is only reported once.
+	CreateStudents(ctx context.Context, emails []string) (created, existing []string, err error)
+	GetStudent(ctx context.Context, email string) (Student, error)
+	// ListStudents returns a page of students matching filter in email
+	// order, together with the total number of matches.
+	ListStudents(ctx context.Context, filter StudentFilter, limit, offset int) (students []Student, total int, err error)
+	// UpdateStudent changes a student's email, carrying their registrations
+	// and suspensions over to the new address.
+	UpdateStudent(ctx context.Context, email, newEmail string) error
+	// DeleteStudent removes a student and their suspension history. A
+	// registered student is only removed, together with the registrations,
+	// if removeRegistrations is set; otherwise ErrInUse is returned.
+	DeleteStudent(ctx context.Context, email string, removeRegistrations bool) error
+}
+
+type RegistrationStore interface {
+	// RegisterStudents registers students to teacher in a single
+	// transaction. Unless partial is set, nothing is registered when any
+	// student is unknown or already registered; with partial, those students
+	// are skipped and reported in the result instead.
+	RegisterStudents(ctx context.Context, teacher string, students []string, partial bool) (RegistrationResult, error)
+	CommonStudents(ctx context.Context, query CommonStudentsQuery) (students []CommonStudent, total int, err error)
+	// DeregisterStudents removes the students from teacher in a single
+	// transaction, reporting the ones that were not registered.
+	DeregisterStudents(ctx context.Context, teacher string, students []string) (DeregistrationResult, error)
+	// DeregisterAll removes every student from teacher and returns them.
+	DeregisterAll(ctx context.Context, teacher string) ([]string, error)
+}
+
+type SuspensionStore interface {
+	// SuspendStudent opens a suspension for the student. It reports true and
+	// records nothing when the student is already suspended.
+	SuspendStudent(ctx context.Context, params SuspendParams) (alreadySuspended bool, err error)
+	// UnsuspendStudent ends the student's active suspension. It reports false
+	// when the student was not suspended.
+	UnsuspendStudent(ctx context.Context, student string) (wasSuspended bool, err error)
+	// SuspensionHistory returns the student's suspensions, oldest first.
+	SuspensionHistory(ctx context.Context, student string) ([]Suspension, error)
+	// ExpireSuspensions ends every suspension whose scheduled end has passed
+	// and returns the affected students.
+	ExpireSuspensions(ctx context.Context) ([]string, error)
+}
+
+// Notification is a message sent by a teacher. Recipients is only filled in
+// by CreateNotification and GetNotification.
+type Notification struct {
+	ID             int64
+	Teacher        string
+	Message        string
+	CreatedAt      time.Time
+	RecipientCount int
+	Recipients     []string
+}
+
+// NotificationFilter narrows ListNotifications to notifications sent by
+// Teacher, received by Student, and sent at or after From and before To.
+// Zero fields do not filter.
+type NotificationFilter struct {
+	Teacher string
+	Student string
+	From    time.Time
+	To      time.Time
+}
+
+type NotificationStore interface {
+	// CreateNotification resolves the recipients of message, the
+	// non-suspended students that are either registered to teacher or listed
+	// in mentioned, and records both in a single transaction,
+	// together with a pending delivery to every recipient on each of channels
+	// and to every webhook subscribed to EventNotificationSent.
+	CreateNotification(ctx context.Context, teacher, message string, mentioned []string, channels []string) (Notification, error)
+	GetNotification(ctx context.Context, id int64) (Notification, error)
+	// ListNotifications returns a page of notifications matching filter,
+	// newest first, together with the total number of matches.
+	ListNotifications(ctx context.Context, filter NotificationFilter, limit, offset int) (notifications []Notification, total int, err error)
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery is a notification in the outbox to be sent to one recipient over
+// one channel. Attempts counts the claims made on it so far, and LastError
+// explains why the latest attempt failed.
+type Delivery struct {
+	ID             int64
+	NotificationID int64
+	Channel        string
+	Recipient      string
+	Teacher        string
+	Message        string
+	Status         string
+	Attempts       int
+	LastError      string
+	DeliveredAt    *time.Time
+}
+
+type DeliveryStore interface {
+	// ClaimDeliveries returns up to limit pending deliveries on channels that
+	// are due, oldest first. Each claim counts as an attempt and hides the
+	// delivery from other claims for lease, so that a delivery held by a
+	// worker that dies is retried once the lease runs out.
+	ClaimDeliveries(ctx context.Context, channels []string, limit int, lease time.Duration) ([]Delivery, error)
+	CompleteDelivery(ctx context.Context, id int64) error
+	// RetryDelivery records why an attempt failed and makes the delivery due
+	// again at retryAt.
+	RetryDelivery(ctx context.Context, id int64, lastError string, retryAt time.Time) error
+	// FailDelivery records why the last attempt failed and gives up on the
+	// delivery.
+	FailDelivery(ctx context.Context, id int64, lastError string) error
+	// ListDeliveries returns the deliveries of a notification ordered by
+	// recipient and channel.
+	ListDeliveries(ctx context.Context, notificationID int64) ([]Delivery, error)
+}
+
+// EventNotificationSent is raised for every notification a teacher sends.
+const EventNotificationSent = "notification.sent"
+
+// Webhook subscribes URL to the events in EventTypes. Secret signs the
+// requests sent to it.
+type Webhook struct {
+	ID         int64
+	URL        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is an event raised by a notification, to be sent to a
+// webhook. Once sent it serves as the webhook's delivery log, with
+// ResponseStatus and LastError describing the latest attempt.
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	EventType      string
+	NotificationID int64
+	Status         string
+	Attempts       int
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	// DeleteWebhook removes the webhook together with its deliveries.
+	DeleteWebhook(ctx context.Context, id int64) error
+	// ClaimWebhookDeliveries claims due deliveries as ClaimDeliveries does.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, id int64, responseStatus int) error
+	// RetryWebhookDelivery records the outcome of a failed attempt, where
+	// responseStatus is 0 if no response arrived, and makes the delivery due
+	// again at retryAt.
+	RetryWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string, retryAt time.Time) error
+	FailWebhookDelivery(ctx context.Context, id int64, responseStatus int, lastError string) error
+	// ListWebhookDeliveries returns a page of the webhook's deliveries,
+	// newest first, together with their total.
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) (deliveries []WebhookDelivery, total int, err error)
+}
+
+// APIKey authenticates a caller. Teacher is set for keys that act as a
+// teacher; only the hash of the key's token is stored. Permissions are those
+// granted to Role and are only filled in by LookupAPIKey.
+type APIKey struct {
+	ID          int64
+	Name        string
+	Role        string
+	Teacher     string
+	CreatedAt   time.Time
+	RevokedAt   *time.Time
+	Permissions []string
+}
+
+type AuthStore interface {
+	// CreateAPIKey stores key under the hash of its token and returns it with
+	// its ID set.
+	CreateAPIKey(ctx context.Context, key APIKey, tokenHash string) (APIKey, error)
+	// LookupAPIKey returns the unrevoked key stored under tokenHash together
+	// with the permissions of its role.
+	LookupAPIKey(ctx context.Context, tokenHash string) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+type Store interface {
+	TeacherStore
+	StudentStore
+	RegistrationStore
+	SuspensionStore
+	NotificationStore
+	DeliveryStore
+	WebhookStore
+	AuthStore
+}
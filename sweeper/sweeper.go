@@ -0,0 +1,42 @@
+package sweeper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// Run ends lapsed time-boxed suspensions once immediately and then every
+// interval until ctx is cancelled.
+func Run(ctx context.Context, s store.SuspensionStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	run(ctx, s, ticker.C)
+}
+
+// run sweeps once and then on every tick, so that tests can drive it.
+func run(ctx context.Context, s store.SuspensionStore, ticks <-chan time.Time) {
+	for {
+		Sweep(ctx, s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+	}
+}
+
+func Sweep(ctx context.Context, s store.SuspensionStore) {
+	expired, err := s.ExpireSuspensions(ctx)
+	if err != nil {
+		slog.Error("expiring suspensions", "err", err)
+		return
+	}
+	for _, student := range expired {
+		slog.Info("suspension expired", "student", student)
+	}
+}
@@ -0,0 +1,73 @@
+package sweeper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leeshuoan/gds-OneCV/store"
+)
+
+// recordingStore hands the result of every sweep to the test, which also
+// orders the sweeps against changes to the clock.
+type recordingStore struct {
+	*store.Memory
+	swept chan []string
+}
+
+func (s recordingStore) ExpireSuspensions(ctx context.Context) ([]string, error) {
+	expired, err := s.Memory.ExpireSuspensions(ctx)
+	s.swept <- expired
+	return expired, err
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Date(2023, 7, 1, 8, 0, 0, 0, time.UTC)
+	memory := store.NewMemory()
+	memory.Now = func() time.Time { return now }
+	if _, _, err := memory.CreateStudents(ctx, []string{"studentmary@gmail.com", "studentbob@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+	until := now.Add(time.Hour)
+	if _, err := memory.SuspendStudent(ctx, store.SuspendParams{Student: "studentmary@gmail.com", Until: &until}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memory.SuspendStudent(ctx, store.SuspendParams{Student: "studentbob@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := recordingStore{Memory: memory, swept: make(chan []string)}
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		run(ctx, s, ticks)
+		close(done)
+	}()
+
+	if expired := <-s.swept; len(expired) != 0 {
+		t.Errorf("Expected nothing to expire on the first sweep; got %v", expired)
+	}
+
+	now = now.Add(2 * time.Hour)
+	ticks <- now
+	if expired := <-s.swept; len(expired) != 1 || expired[0] != "studentmary@gmail.com" {
+		t.Errorf("Expected studentmary@gmail.com to expire on the next tick; got %v", expired)
+	}
+
+	if student, _ := memory.GetStudent(ctx, "studentmary@gmail.com"); student.Suspended {
+		t.Errorf("Expected studentmary@gmail.com to be unsuspended")
+	}
+	if student, _ := memory.GetStudent(ctx, "studentbob@gmail.com"); !student.Suspended {
+		t.Errorf("Expected studentbob@gmail.com to remain suspended")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to stop once the context is cancelled")
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/leeshuoan/gds-OneCV/models"
+)
+
+// RequestIDHeader carries the ID that ties a response to the server's log
+// line for the request.
+const RequestIDHeader = "X-Request-ID"
+
+// ProblemContentType is the media type of every error response.
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix turns a problem code into its type URI.
+const problemTypePrefix = "urn:gds-onecv:problem:"
+
+// Codes for problems that are not about a particular stored entity, which
+// get codes such as "teacher_not_found" instead.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object. Code is stable and can be
+// matched on by clients; Type is derived from it. Field and Email name the
+// part of the request the problem is about, and Errors lists every invalid
+// field of a request that failed validation.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Code      string              `json:"code"`
+	Field     string              `json:"field,omitempty"`
+	Email     string              `json:"email,omitempty"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+	RequestID string              `json:"requestId,omitempty"`
+}
+
+// SendProblem writes problem, filling in its type, title and request ID.
+func SendProblem(w http.ResponseWriter, problem Problem) {
+	if problem.Code == "" {
+		problem.Code = statusCode(problem.Status)
+	}
+	problem.Type = problemTypePrefix + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	problem.RequestID = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// SendJSONError writes a problem with the generic code for statusCode.
+func SendJSONError(w http.ResponseWriter, statusCode int, message string) {
+	SendProblem(w, Problem{Status: statusCode, Detail: message})
+}
+
+// SendFieldErrors reports invalid request fields, listing each one in
+// "errors" and summarising them in "detail".
+func SendFieldErrors(w http.ResponseWriter, statusCode int, errs []models.FieldError) {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.String()
+	}
+	SendProblem(w, Problem{
+		Status: statusCode,
+		Code:   CodeValidationFailed,
+		Detail: strings.Join(messages, "; "),
+		Errors: errs,
+	})
+}
+
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusInternalServerError:
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -1,25 +1,28 @@
 package utils
 
 import (
-	"net/http"
-	"encoding/json"
 	"strings"
-)
 
-func SendJSONError(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	response := map[string]string{"message": message}
-	json.NewEncoder(w).Encode(response)
-}
+	"github.com/leeshuoan/gds-OneCV/models"
+)
 
+// ParseMentionedStudents returns the canonical emails of the students
+// mentioned as @email in a notification, in order and without duplicates.
+// Mentions that are not valid emails are ignored.
 func ParseMentionedStudents(notificationText string) []string {
 	mentionedStudents := []string{}
+	seen := make(map[string]bool)
 	words := strings.Fields(notificationText)
 	for _, word := range words {
-		if strings.HasPrefix(word, "@") && strings.Contains(word, "@") {
-			mentionedStudents = append(mentionedStudents, strings.TrimPrefix(word, "@"))
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		email, ok := models.NormalizeEmail(strings.TrimPrefix(word, "@"))
+		if !ok || seen[email] {
+			continue
 		}
+		seen[email] = true
+		mentionedStudents = append(mentionedStudents, email)
 	}
 
 	return mentionedStudents